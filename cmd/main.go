@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"dockerizer-cli/internal/analyzer"
 	"dockerizer-cli/internal/generator"
+	"dockerizer-cli/internal/registry"
 
 	"github.com/manifoldco/promptui"
 	"github.com/urfave/cli/v2"
@@ -25,18 +28,83 @@ func main() {
 			{
 				Name:  "init",
 				Usage: "Initialize and analyze the project",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "root",
+						Usage: "run the application as root instead of an unprivileged user",
+					},
+					&cli.StringFlag{
+						Name:  "runtime",
+						Usage: "runtime image flavor from the language catalog, e.g. distroless or scratch",
+					},
+					&cli.StringSliceFlag{
+						Name:  "platform",
+						Usage: "target platforms of docker-bake.hcl, e.g. linux/amd64,linux/arm64",
+						Value: cli.NewStringSlice(generator.DefaultPlatforms...),
+					},
+					&cli.BoolFlag{
+						Name:    "legacy-builder",
+						Usage:   "generate a Dockerfile for the legacy builder, without BuildKit cache mounts",
+						EnvVars: []string{"DOCKERIZER_LEGACY_BUILDER"},
+					},
+				},
 				Action: func(c *cli.Context) error {
 					fmt.Println("🔍 Analyzing project structure...")
 
 					// Analyze project
-					project, err := analyzer.AnalyzeProject(".")
+					projectPath := "."
+					project, err := analyzer.AnalyzeProject(projectPath)
 					if err != nil {
 						return fmt.Errorf("failed to analyze project: %w", err)
 					}
 
+					// At the root of a monorepo, dockerize one of its packages
+					if project.Workspace != nil && project.Workspace.Name == "" {
+						projectPath, err = selectWorkspacePackage(project.Workspace)
+						if err != nil {
+							return err
+						}
+						project, err = analyzer.AnalyzeProject(projectPath)
+						if err != nil {
+							return fmt.Errorf("failed to analyze %s: %w", projectPath, err)
+						}
+					}
+					if workspace := project.Workspace; workspace != nil && workspace.Name != "" {
+						fmt.Printf("📦 Found %s workspace, building %s (%d packages in the build context)\n",
+							workspace.Manager, workspace.Name, len(workspace.Members))
+					}
+
+					// Import existing Docker files as a starting point
+					if err := analyzer.ImportExisting(projectPath, project); err != nil {
+						fmt.Printf("⚠️  Warning: %v\n", err)
+					}
+					if project.ExistingDockerfile != nil {
+						fmt.Println("📄 Found existing Dockerfile")
+					}
+					if project.ExistingCompose != nil {
+						fmt.Printf("📄 Found existing %s\n", filepath.Base(project.ExistingCompose.Path))
+					}
+
+					// Import Heroku process and add-on declarations
+					if err := analyzer.ImportHeroku(projectPath, project); err != nil {
+						fmt.Printf("⚠️  Warning: %v\n", err)
+					}
+					if project.Heroku != nil {
+						fmt.Printf("📄 Found Procfile/app.json with %d process types and %d add-ons\n",
+							len(project.Heroku.Processes), len(project.Heroku.Addons))
+					}
+
+					// Private registry credentials are passed as build secrets
+					for _, warning := range analyzer.DetectSecrets(projectPath, project) {
+						fmt.Printf("⚠️  Warning: %s\n", warning)
+					}
+					for _, secret := range project.Secrets {
+						fmt.Printf("🔑 Passing %s to the build as a secret\n", secret.ID)
+					}
+
 					if project.Language == "" {
 						fmt.Println("❌ Could not automatically detect the project language.")
-						return selectLanguageManually(project)
+						return selectLanguageManually(projectPath, project)
 					}
 
 					// Confirm language detection
@@ -45,20 +113,20 @@ func main() {
 					var response string
 					fmt.Scanln(&response)
 					if response != "" && strings.ToLower(response) != "y" {
-						return selectLanguageManually(project)
+						return selectLanguageManually(projectPath, project)
 					}
 
 					// Framework detection
 					if project.Framework == "" {
 						fmt.Println("❌ Could not automatically detect the framework.")
-						return selectFrameworkManually(project)
+						return selectFrameworkManually(projectPath, project)
 					}
 
 					fmt.Printf("✨ Detected %s framework\n", project.Framework)
 					fmt.Print("Is this correct? [Y/n]: ")
 					fmt.Scanln(&response)
 					if response != "" && strings.ToLower(response) != "y" {
-						return selectFrameworkManually(project)
+						return selectFrameworkManually(projectPath, project)
 					}
 
 					// Port configuration
@@ -83,10 +151,27 @@ func main() {
 						}
 					}
 
+					// Health endpoint for the HEALTHCHECK probe
+					analyzer.DetectHealthCheck(projectPath, project)
+
+					// OCI labels from the manifest, LICENSE and git remote
+					analyzer.DetectMetadata(projectPath, project)
+
+					// Dependencies that compile native code for the target platform
+					analyzer.DetectNativeModules(projectPath, project)
+					analyzer.DetectTestCommand(projectPath, project)
+
+					// Production server of Python web apps
+					analyzer.DetectAppServer(projectPath, project)
+
 					// Database selection
-					fmt.Print("Does your project need a database? [Y/n]: ")
-					fmt.Scanln(&response)
-					if response == "" || strings.ToLower(response) == "y" {
+					if project.Database != "" {
+						fmt.Printf("✨ Using %s from the existing configuration\n", project.Database)
+					} else {
+						fmt.Print("Does your project need a database? [Y/n]: ")
+						fmt.Scanln(&response)
+					}
+					if project.Database == "" && (response == "" || strings.ToLower(response) == "y") {
 						// Load database options from config
 						dbConfig, err := loadDatabaseConfig()
 						if err != nil {
@@ -142,30 +227,167 @@ func main() {
 						}
 					}
 
+					// Offer to update hand-written files instead of overwriting them
+					if project.ExistingDockerfile != nil || project.ExistingCompose != nil {
+						fmt.Print("Update the existing Docker files in place instead of regenerating them? [Y/n]: ")
+						response = ""
+						fmt.Scanln(&response)
+						project.UpdateInPlace = response == "" || strings.ToLower(response) == "y"
+					}
+
+					// Docker compose only reads the first compose file it finds, so a
+					// regenerated one replaces the existing file
+					if !project.UpdateInPlace && project.ExistingCompose != nil {
+						name := filepath.Base(project.ExistingCompose.Path)
+						fmt.Printf("Overwrite %s with the generated services? [y/N]: ", name)
+						response = ""
+						fmt.Scanln(&response)
+						if strings.ToLower(response) != "y" {
+							return fmt.Errorf("%s left unchanged, a docker-compose.yml next to it would be ignored by docker compose", name)
+						}
+					}
+
+					// Generated images run as an unprivileged user unless asked not to
+					project.RunAsRoot = c.Bool("root")
+					project.Flavor = c.String("runtime")
+					project.Platforms = c.StringSlice("platform")
+
+					// DOCKER_BUILDKIT=0 selects the legacy builder for docker build
+					project.LegacyBuilder = c.Bool("legacy-builder") || os.Getenv("DOCKER_BUILDKIT") == "0"
+
 					fmt.Println("\n📦 Generating Docker files...")
 
 					// Generate Dockerfile
-					if err := generator.GenerateDockerfile(project, "."); err != nil {
+					if err := generator.GenerateDockerfile(project, projectPath); err != nil {
 						fmt.Printf("⚠️  Warning: %v\n", err)
 						fmt.Println("Continuing with docker-compose.yml generation...")
+					} else if project.UpdateInPlace && project.ExistingDockerfile != nil {
+						fmt.Println("✅ Successfully updated Dockerfile")
 					} else {
 						fmt.Println("✅ Successfully generated Dockerfile")
 					}
 
+					// Keep dependencies, build output and local files out of the build context
+					if _, err := os.Stat(filepath.Join(projectPath, "Dockerfile")); err == nil {
+						warnings, err := generator.GenerateDockerignore(project, projectPath)
+						if err != nil {
+							fmt.Printf("⚠️  Warning: %v\n", err)
+						} else {
+							fmt.Println("✅ Successfully generated .dockerignore")
+						}
+						for _, warning := range warnings {
+							fmt.Printf("⚠️  Warning: %s\n", warning)
+						}
+					}
+
+					// Multi-platform builds with docker buildx bake
+					if _, err := os.Stat(filepath.Join(projectPath, "Dockerfile")); err == nil {
+						if err := generator.GenerateBake(project, projectPath); err != nil {
+							fmt.Printf("⚠️  Warning: %v\n", err)
+						} else {
+							fmt.Println("✅ Successfully generated docker-bake.hcl")
+						}
+					}
+
 					// Generate docker-compose.yml
-					if err := generator.GenerateCompose(project, "."); err != nil {
+					if err := generator.GenerateCompose(project, projectPath); err != nil {
 						return fmt.Errorf("failed to generate docker-compose.yml: %w", err)
 					}
-					fmt.Println("✅ Successfully generated docker-compose.yml")
+					if project.UpdateInPlace && project.ExistingCompose != nil {
+						fmt.Printf("✅ Successfully updated %s\n", filepath.Base(project.ExistingCompose.Path))
+					} else if project.ExistingCompose != nil {
+						fmt.Printf("✅ Successfully regenerated %s\n", filepath.Base(project.ExistingCompose.Path))
+					} else {
+						fmt.Println("✅ Successfully generated docker-compose.yml")
+					}
+
+					// Run the dev stage with the source mounted during development
+					overridePath := generator.ComposeOverridePath(project, projectPath)
+					dev := false
+					if _, err := os.Stat(filepath.Join(projectPath, "Dockerfile")); err == nil {
+						if err := generator.GenerateComposeOverride(project, projectPath); err != nil {
+							fmt.Printf("⚠️  Warning: %v\n", err)
+						} else {
+							fmt.Printf("✅ Successfully generated %s\n", filepath.Base(overridePath))
+							dev = true
+						}
+					}
+
+					// Run the tests against throwaway databases
+					tests := false
+					if _, err := os.Stat(filepath.Join(projectPath, "Dockerfile")); err == nil && project.TestCommand != "" {
+						if err := generator.GenerateComposeTest(project, projectPath); err != nil {
+							fmt.Printf("⚠️  Warning: %v\n", err)
+						} else {
+							fmt.Println("✅ Successfully generated compose.test.yml")
+							tests = true
+						}
+					}
 
 					fmt.Println("\nNext steps:")
 					fmt.Println("1. Review the generated files")
-					fmt.Println("2. Build and run your container:")
-					fmt.Println("   docker-compose up --build")
+					cd := ""
+					if projectPath != "." {
+						cd = "cd " + projectPath + " && "
+					}
+					if dev {
+						fmt.Println("2. Start the development environment, with live reload and debugger ports:")
+						fmt.Printf("   %sdocker-compose up --build\n", cd)
+						fmt.Println("3. Run the production image:")
+						fmt.Printf("   %sdocker-compose -f %s up --build\n", cd, strings.Replace(filepath.Base(overridePath), ".override", "", 1))
+					} else {
+						fmt.Println("2. Build and run your container:")
+						fmt.Printf("   %sdocker-compose up --build\n", cd)
+					}
+					if tests {
+						step := 3
+						if dev {
+							step = 4
+						}
+						fmt.Printf("%d. Run the tests:\n", step)
+						fmt.Printf("   %sdocker-compose -f compose.test.yml up --build --exit-code-from app\n", cd)
+					}
 
 					return nil
 				},
 			},
+			{
+				Name:  "lock",
+				Usage: "Pin the project's images to digests in dockerizer.lock",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "update",
+						Usage:     "Resolve the current digest of every image and pin the Docker files to them",
+						ArgsUsage: "[project directory]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "registry",
+								Usage:   "registry queried for Docker Hub images instead of Docker Hub, e.g. localhost:5000",
+								EnvVars: []string{"DOCKERIZER_REGISTRY"},
+							},
+						},
+						Action: func(c *cli.Context) error {
+							projectPath := "."
+							if c.NArg() > 0 {
+								projectPath = c.Args().First()
+							}
+							client := &registry.Client{
+								Mirror: c.String("registry"),
+								HTTP:   &http.Client{Timeout: 30 * time.Second},
+							}
+							refs, err := generator.UpdateLock(projectPath, client)
+							if err != nil {
+								return err
+							}
+							for _, ref := range refs {
+								fmt.Printf("🔒 %s\n", ref)
+							}
+							fmt.Printf("✅ Pinned %d images in %s\n", len(refs), generator.LockFile)
+							return nil
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -174,7 +396,7 @@ func main() {
 	}
 }
 
-func selectLanguageManually(project *analyzer.ProjectType) error {
+func selectLanguageManually(path string, project *analyzer.ProjectType) error {
 	// Get available languages from config files
 	files, err := filepath.Glob("supported/*.yaml")
 	if err != nil {
@@ -205,10 +427,10 @@ func selectLanguageManually(project *analyzer.ProjectType) error {
 	}
 
 	project.Language = language
-	return selectFrameworkManually(project)
+	return selectFrameworkManually(path, project)
 }
 
-func selectFrameworkManually(project *analyzer.ProjectType) error {
+func selectFrameworkManually(path string, project *analyzer.ProjectType) error {
 	// Find the language config file
 	files, err := filepath.Glob("supported/*.yaml")
 	if err != nil {
@@ -216,6 +438,7 @@ func selectFrameworkManually(project *analyzer.ProjectType) error {
 	}
 
 	var frameworks []string
+	var language *analyzer.LanguageConfig
 	for _, file := range files {
 		if file == "supported/databases.yaml" {
 			continue
@@ -227,9 +450,8 @@ func selectFrameworkManually(project *analyzer.ProjectType) error {
 		}
 
 		if config.Name == project.Language {
-			for name := range config.Frameworks {
-				frameworks = append(frameworks, name)
-			}
+			frameworks = config.FrameworkNames()
+			language = config
 			break
 		}
 	}
@@ -244,10 +466,33 @@ func selectFrameworkManually(project *analyzer.ProjectType) error {
 		return fmt.Errorf("framework selection failed: %w", err)
 	}
 
-	project.Framework = framework
+	analyzer.ApplyFramework(path, project, framework, language.Frameworks[framework])
 	return nil
 }
 
+func selectWorkspacePackage(workspace *analyzer.Workspace) (string, error) {
+	apps := workspace.Applications()
+	if len(apps) == 0 {
+		return "", fmt.Errorf("no workspace package has a start or build script")
+	}
+
+	var items []string
+	for _, app := range apps {
+		items = append(items, fmt.Sprintf("%s (%s)", app.Name, app.Dir))
+	}
+
+	selectPrompt := promptui.Select{
+		Label: "This is a workspace root. Select the package to dockerize",
+		Items: items,
+	}
+
+	index, _, err := selectPrompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("package selection failed: %w", err)
+	}
+	return filepath.Join(workspace.Root, apps[index].Dir), nil
+}
+
 type DatabaseConfig struct {
 	Databases map[string]struct {
 		Name        string   `yaml:"name"`
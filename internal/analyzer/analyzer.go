@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -20,6 +22,66 @@ type ProjectType struct {
 	Ports        []string
 	Database     string
 	Environment  []string
+	Version      string
+	AppName      string
+	ProjectFile  string
+	ProjectFiles []string
+	HasAssets    bool
+	EntryPoint   string
+	RuntimeFlags []string
+	StaticSite   bool
+	OutputDir    string
+
+	// Background processes and the services they need (e.g. redis, rabbitmq)
+	Processes          []Process
+	Broker             string
+	AdditionalServices []string
+
+	// Command overrides the image's default command for the app service
+	Command string
+	// ServiceURLs maps environment variables to the service they connect to
+	ServiceURLs map[string]string
+	Heroku      *HerokuApp
+
+	// Workspace is the monorepo the project is a package of, if any
+	Workspace *Workspace
+
+	// Credentials for private registries, passed to the build as secrets
+	Secrets   []BuildSecret
+	GoPrivate string
+
+	// RunAsRoot keeps the runtime image running as root instead of an
+	// unprivileged user
+	RunAsRoot bool
+	// Flavor is the runtime image flavor, empty for the language's default
+	Flavor string
+	// LegacyBuilder leaves out BuildKit features such as cache mounts
+	LegacyBuilder bool
+	// HealthCheck is the HTTP path the container is probed on, empty to
+	// only check that the port accepts connections
+	HealthCheck string
+	// Metadata is published in the image's OCI labels
+	Metadata *ImageMetadata
+	// NativeModules are dependencies that may compile native code for the
+	// target platform
+	NativeModules []string
+	// Platforms are the target platforms of multi-architecture builds
+	Platforms []string
+	// DevServer is the framework whose dev server the dev stage runs when
+	// it is not the project's own, e.g. vite for React apps built with Vite
+	DevServer string
+	// TestCommand runs the tests in the test stage, empty when no test
+	// runner is set up
+	TestCommand string
+	// TestInstall installs the test dependencies the dev stage lacks
+	TestInstall string
+	// AppServer serves Python web apps in the production image
+	AppServer *AppServer
+
+	// Hand-written Docker files found in the project, if any
+	ExistingDockerfile *ExistingDockerfile
+	ExistingCompose    *ExistingCompose
+	UpdateInPlace      bool
 }
 
 // LanguageConfig represents a language configuration from YAML
@@ -29,6 +91,22 @@ type LanguageConfig struct {
 	BaseImage      string                     `yaml:"base_image"`
 	BuildFlags     []string                   `yaml:"build_flags,omitempty"`
 	Frameworks     map[string]FrameworkConfig `yaml:"frameworks"`
+	// Templates maps build variants (default, static, server, workspace)
+	// to Dockerfile templates in supported/templates, and dev to the
+	// development stage
+	Templates map[string]string `yaml:"templates,omitempty"`
+	// Runtime lists the production images the language can be built with
+	Runtime RuntimeConfig `yaml:"runtime,omitempty"`
+	// Dockerignore lists the dependency, build output and local files the
+	// build context never needs, in .dockerignore syntax
+	Dockerignore []string `yaml:"dockerignore,omitempty"`
+	// NativeBuild lists the dependencies that compile native code
+	NativeBuild NativeBuildConfig `yaml:"native_build,omitempty"`
+	// DevVolumes are directories the dev stage installs dependencies into,
+	// relative to its working directory
+	DevVolumes []string `yaml:"dev_volumes,omitempty"`
+	// Test lists the test runners of the test stage
+	Test TestConfig `yaml:"test,omitempty"`
 }
 
 // FrameworkConfig represents a framework configuration from YAML
@@ -42,6 +120,59 @@ type FrameworkConfig struct {
 	DatabaseOptions []string `yaml:"database_options,omitempty"`
 	Environment     []string `yaml:"environment,omitempty"`
 	FilePermissions []string `yaml:"file_permissions,omitempty"`
+	Priority        int      `yaml:"priority,omitempty"`
+	RuntimeFlags    []string `yaml:"runtime_flags,omitempty"`
+	Static          bool     `yaml:"static,omitempty"`
+	OutputDir       string   `yaml:"output_dir,omitempty"`
+	// Template overrides the language's default Dockerfile template
+	Template string `yaml:"template,omitempty"`
+	// HealthCheck is the HTTP path that reports the application healthy
+	HealthCheck string `yaml:"health_check,omitempty"`
+	// DevPort is the dev server's port when it differs from Port
+	DevPort int `yaml:"dev_port,omitempty"`
+	// DevEnvironment overrides the production environment in development
+	DevEnvironment []string `yaml:"dev_environment,omitempty"`
+	// Server runs the app with a production server instead of StartCommand
+	Server *AppServerConfig `yaml:"server,omitempty"`
+}
+
+// FrameworkNames returns the framework keys ordered by priority (highest
+// first) and then by name, so that detection is deterministic when a project
+// matches several frameworks (e.g. Phoenix also depends on Plug).
+func (c *LanguageConfig) FrameworkNames() []string {
+	names := make([]string, 0, len(c.Frameworks))
+	for name := range c.Frameworks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := c.Frameworks[names[i]].Priority, c.Frameworks[names[j]].Priority
+		if pi != pj {
+			return pi > pj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// FindLanguageConfig returns the catalog entry of a language
+func FindLanguageConfig(name string) (*LanguageConfig, error) {
+	files, err := filepath.Glob("supported/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supported languages: %w", err)
+	}
+	for _, file := range files {
+		if file == "supported/databases.yaml" {
+			continue
+		}
+		config, err := loadLanguageConfig(file)
+		if err != nil {
+			continue
+		}
+		if config.Name == name {
+			return config, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported language: %s", name)
 }
 
 // loadLanguageConfig loads language configuration from YAML file
@@ -92,6 +223,7 @@ func AnalyzeProject(path string) (*ProjectType, error) {
 						project.Language = "PHP"
 						project.Framework = "laravel"
 						project.Ports = []string{"9000"}
+						detectLaravelProcesses(path, project)
 						return project, nil
 					}
 				}
@@ -99,7 +231,10 @@ func AnalyzeProject(path string) (*ProjectType, error) {
 		}
 	}
 
-	// Check other languages
+	// Check other languages. Files are visited in name order, so bun.yaml and
+	// deno.yaml claim their projects before nodejs.yaml sees the package.json.
+	// A language whose framework detection fails leaves the project to the
+	// next one.
 	for _, langFile := range supportedLangs {
 		if langFile == "supported/databases.yaml" {
 			continue
@@ -112,7 +247,7 @@ func AnalyzeProject(path string) (*ProjectType, error) {
 
 		// Check if any of the file indicators exist
 		for _, indicator := range config.FileIndicators {
-			if foundFiles[indicator] {
+			if hasIndicator(foundFiles, indicator) {
 				project.Language = config.Name
 				// Try to detect framework
 				if err := detectFramework(path, project, config); err == nil {
@@ -126,6 +261,54 @@ func AnalyzeProject(path string) (*ProjectType, error) {
 	return project, nil
 }
 
+// ApplyFramework sets the framework on the project together with the
+// defaults from its catalog entry. It is used both by detection and when the
+// framework is selected manually.
+func ApplyFramework(path string, project *ProjectType, name string, framework FrameworkConfig) {
+	project.Framework = name
+	if framework.Port != 0 {
+		project.Ports = []string{fmt.Sprintf("%d", framework.Port)}
+	}
+	project.RuntimeFlags = framework.RuntimeFlags
+	project.StaticSite = framework.Static
+	project.OutputDir = ""
+	if project.Language == "Node.js" {
+		project.EntryPoint = ""
+		detectAdapter(path, project)
+
+		// React, Vue and Svelte apps are often built with Vite rather than
+		// their own tooling
+		project.DevServer = ""
+		switch name {
+		case "react", "vue", "svelte":
+			if packageDependency(path, "vite") {
+				project.DevServer = "vite"
+			}
+		}
+	}
+	if project.StaticSite {
+		// Static sites are served by nginx, unprivileged on 8080
+		project.Ports = []string{"8080"}
+		if project.OutputDir == "" {
+			project.OutputDir = detectOutputDir(path, name, framework.OutputDir)
+		}
+	}
+}
+
+// hasIndicator reports whether a file indicator is present. Indicators may be
+// plain file names or glob patterns such as "*.csproj".
+func hasIndicator(foundFiles map[string]bool, indicator string) bool {
+	if !strings.ContainsAny(indicator, "*?[") {
+		return foundFiles[indicator]
+	}
+	for name := range foundFiles {
+		if matched, _ := filepath.Match(indicator, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 func detectFramework(path string, project *ProjectType, config *LanguageConfig) error {
 	switch project.Language {
 	case "Node.js":
@@ -136,11 +319,27 @@ func detectFramework(path string, project *ProjectType, config *LanguageConfig)
 		return detectGoFramework(path, project, config)
 	case "PHP":
 		return detectPHPFramework(path, project, config)
+	case ".NET":
+		return detectDotnetFramework(path, project, config)
+	case "Elixir":
+		return detectElixirFramework(path, project, config)
+	case "Deno":
+		return detectDenoFramework(path, project, config)
+	case "Bun":
+		return detectBunFramework(path, project, config)
+	case "Hugo":
+		return detectHugoFramework(path, project, config)
 	}
 	return nil
 }
 
 func detectNodeFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	workspace, err := FindWorkspace(path)
+	if err != nil {
+		return err
+	}
+	project.Workspace = workspace
+
 	packageJSONPath := filepath.Join(path, "package.json")
 	data, err := ioutil.ReadFile(packageJSONPath)
 	if err != nil {
@@ -152,7 +351,7 @@ func detectNodeFramework(path string, project *ProjectType, config *LanguageConf
 		DevDependencies map[string]string `json:"devDependencies"`
 	}
 
-	if err := yaml.Unmarshal(data, &packageJSON); err != nil {
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
 		return err
 	}
 
@@ -166,13 +365,11 @@ func detectNodeFramework(path string, project *ProjectType, config *LanguageConf
 	}
 
 	// Check each framework
-	for name, framework := range config.Frameworks {
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
 		for _, dep := range framework.Dependencies {
 			if _, ok := deps[dep]; ok {
-				project.Framework = name
-				if framework.Port != 0 {
-					project.Ports = []string{fmt.Sprintf("%d", framework.Port)}
-				}
+				ApplyFramework(path, project, name, framework)
 				return nil
 			}
 		}
@@ -182,6 +379,8 @@ func detectNodeFramework(path string, project *ProjectType, config *LanguageConf
 }
 
 func detectPythonFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	detectPythonWorkers(path, project)
+
 	reqPath := filepath.Join(path, "requirements.txt")
 	data, err := ioutil.ReadFile(reqPath)
 	if err != nil {
@@ -189,13 +388,11 @@ func detectPythonFramework(path string, project *ProjectType, config *LanguageCo
 	}
 
 	content := string(data)
-	for name, framework := range config.Frameworks {
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
 		for _, dep := range framework.Dependencies {
 			if strings.Contains(content, dep) {
-				project.Framework = name
-				if framework.Port != 0 {
-					project.Ports = []string{fmt.Sprintf("%d", framework.Port)}
-				}
+				ApplyFramework(path, project, name, framework)
 				return nil
 			}
 		}
@@ -205,20 +402,67 @@ func detectPythonFramework(path string, project *ProjectType, config *LanguageCo
 }
 
 func detectGoFramework(path string, project *ProjectType, config *LanguageConfig) error {
-	modPath := filepath.Join(path, "go.mod")
-	data, err := ioutil.ReadFile(modPath)
+	mod, err := ParseGoMod(filepath.Join(path, "go.mod"))
+	if err != nil {
+		return err
+	}
+	// The golang image follows the go directive
+	project.Version = mod.Go
+
+	// Local modules from go.work or replace directives must be in the build context
+	workspace, err := FindGoWorkspace(path)
+	if err != nil {
+		return err
+	}
+	project.Workspace = workspace
+
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
+		for _, dep := range framework.Dependencies {
+			if goRequires(mod, dep) {
+				ApplyFramework(path, project, name, framework)
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func detectElixirFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	mixPath := filepath.Join(path, "mix.exs")
+	data, err := ioutil.ReadFile(mixPath)
 	if err != nil {
 		return err
 	}
 
 	content := string(data)
-	for name, framework := range config.Frameworks {
+
+	// The OTP application name is also the release name
+	re := regexp.MustCompile(`app:\s*:(\w+)`)
+	if matches := re.FindStringSubmatch(content); len(matches) > 1 {
+		project.AppName = matches[1]
+	}
+	project.HasAssets = strings.Contains(content, `"assets.deploy"`)
+
+	// Ecto needs a database, the adapter dependency tells which one.
+	// ecto_sql without a listed adapter gets Postgres, Phoenix's default.
+	switch {
+	case strings.Contains(content, "{:myxql,"):
+		project.Database = "mysql"
+	case strings.Contains(content, "{:postgrex,"), strings.Contains(content, "{:ecto_sql,"):
+		project.Database = "postgres"
+	}
+
+	if version, err := detectElixirVersion(path); err == nil {
+		project.Version = version
+	}
+
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
 		for _, dep := range framework.Dependencies {
 			if strings.Contains(content, dep) {
-				project.Framework = name
-				if framework.Port != 0 {
-					project.Ports = []string{fmt.Sprintf("%d", framework.Port)}
-				}
+				ApplyFramework(path, project, name, framework)
 				return nil
 			}
 		}
@@ -242,13 +486,11 @@ func detectPHPFramework(path string, project *ProjectType, config *LanguageConfi
 		return err
 	}
 
-	for name, framework := range config.Frameworks {
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
 		for _, dep := range framework.Dependencies {
 			if _, ok := composer.Require[dep]; ok {
-				project.Framework = name
-				if framework.Port != 0 {
-					project.Ports = []string{fmt.Sprintf("%d", framework.Port)}
-				}
+				ApplyFramework(path, project, name, framework)
 				return nil
 			}
 		}
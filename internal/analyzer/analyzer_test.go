@@ -0,0 +1,160 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests from the repository root, where the catalog is
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// analyze writes files to a new project directory and analyzes it
+func analyze(t *testing.T, files map[string]string) *ProjectType {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	project, err := AnalyzeProject(dir)
+	if err != nil {
+		t.Fatalf("AnalyzeProject: %v", err)
+	}
+	return project
+}
+
+func TestDetectBunLockfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		language  string
+		framework string
+	}{
+		{
+			name: "Next.js installed with bun",
+			files: map[string]string{
+				"package.json": `{"scripts": {"start": "next start"}, "dependencies": {"next": "14.0.0", "react": "18.2.0"}}`,
+				"bun.lockb":    "",
+			},
+			language:  "Node.js",
+			framework: "nextjs",
+		},
+		{
+			name: "Hono on bun",
+			files: map[string]string{
+				"package.json": `{"scripts": {"start": "bun run src/index.ts"}, "dependencies": {"hono": "4.0.0"}}`,
+				"bun.lock":     "",
+				"src/index.ts": "",
+			},
+			language:  "Bun",
+			framework: "hono",
+		},
+		{
+			name: "bun init project",
+			files: map[string]string{
+				"package.json": `{"name": "app", "module": "index.ts"}`,
+				"bun.lockb":    "",
+				"index.ts":     "",
+			},
+			language: "Bun",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := analyze(t, tt.files)
+			if project.Language != tt.language || project.Framework != tt.framework {
+				t.Errorf("got %s/%s, want %s/%s", project.Language, project.Framework, tt.language, tt.framework)
+			}
+		})
+	}
+}
+
+func TestDetectDenoDefaults(t *testing.T) {
+	project := analyze(t, map[string]string{
+		"deno.json": `{"tasks": {"start": "deno run --allow-net main.ts"}}`,
+		"main.ts":   `Deno.serve(() => new Response("ok"))`,
+	})
+	if project.Language != "Deno" || project.EntryPoint != "main.ts" {
+		t.Fatalf("got %s with entry %q, want Deno with main.ts", project.Language, project.EntryPoint)
+	}
+	if len(project.Ports) != 1 || project.Ports[0] != "8000" {
+		t.Errorf("got ports %v, want Deno.serve's 8000", project.Ports)
+	}
+	if project.Version != DefaultDenoVersion {
+		t.Errorf("got version %q, want the pinned %s", project.Version, DefaultDenoVersion)
+	}
+}
+
+func TestDetectViteOutputDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "react-scripts",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"react": "18.2.0", "react-scripts": "5.0.1"}}`,
+			},
+			want: "build",
+		},
+		{
+			name: "react with vite",
+			files: map[string]string{
+				"package.json":   `{"dependencies": {"react": "18.2.0"}, "devDependencies": {"vite": "5.0.0"}}`,
+				"vite.config.ts": "export default defineConfig({ plugins: [react()] })\n",
+			},
+			want: "dist",
+		},
+		{
+			name: "vite outDir",
+			files: map[string]string{
+				"package.json":   `{"dependencies": {"react": "18.2.0"}, "devDependencies": {"vite": "5.0.0"}}`,
+				"vite.config.ts": "export default defineConfig({ build: { outDir: './public/app/' } })\n",
+			},
+			want: "public/app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := analyze(t, tt.files)
+			if project.Framework != "react" || !project.StaticSite || project.OutputDir != tt.want {
+				t.Errorf("got %s static=%v output %q, want a static react site in %s", project.Framework, project.StaticSite, project.OutputDir, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectEctoDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		deps     string
+		database string
+	}{
+		{"phoenix with ecto", `{:phoenix, "~> 1.7"}, {:phoenix_ecto, "~> 4.4"}, {:ecto_sql, "~> 3.10"}, {:postgrex, ">= 0.0.0"}`, "postgres"},
+		{"phoenix --no-ecto", `{:phoenix, "~> 1.7"}, {:jason, "~> 1.2"}`, ""},
+		{"plug with ecto", `{:plug_cowboy, "~> 2.6"}, {:ecto_sql, "~> 3.10"}`, "postgres"},
+		{"phoenix with myxql", `{:phoenix, "~> 1.7"}, {:ecto_sql, "~> 3.10"}, {:myxql, ">= 0.0.0"}`, "mysql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := analyze(t, map[string]string{
+				"mix.exs": "defmodule App.MixProject do\n  def project, do: [app: :app, deps: deps()]\n  defp deps, do: [" + tt.deps + "]\nend\n",
+			})
+			if project.Database != tt.database {
+				t.Errorf("got database %q, want %q", project.Database, tt.database)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dotnetRestoreFiles are solution-level files that influence `dotnet restore`
+// and therefore have to be copied before the restore layer.
+var dotnetRestoreFiles = []string{
+	"global.json",
+	"nuget.config",
+	"NuGet.config",
+	"Directory.Build.props",
+	"Directory.Build.targets",
+	"Directory.Packages.props",
+}
+
+func detectDotnetFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	projects, err := findDotnetProjects(path)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("no .csproj file found")
+	}
+
+	// Prefer a web project as the entry point, then a worker, then anything
+	entry := projects[0]
+	contents := make(map[string]string)
+	for _, proj := range projects {
+		data, err := ioutil.ReadFile(filepath.Join(path, proj))
+		if err != nil {
+			continue
+		}
+		contents[proj] = string(data)
+	}
+	for _, sdk := range []string{"Microsoft.NET.Sdk.Web", "Microsoft.NET.Sdk.Worker"} {
+		found := false
+		for _, proj := range projects {
+			if strings.Contains(contents[proj], sdk) {
+				entry = proj
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	project.ProjectFile = entry
+	project.ProjectFiles = nil
+	for _, name := range dotnetRestoreFiles {
+		if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+			project.ProjectFiles = append(project.ProjectFiles, name)
+		}
+	}
+	project.ProjectFiles = append(project.ProjectFiles, projects...)
+	project.AppName = dotnetAssemblyName(entry, contents[entry])
+
+	if version, err := detectDotnetVersion(path); err == nil {
+		project.Version = version
+	}
+
+	content := contents[entry]
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
+		for _, dep := range framework.Dependencies {
+			if strings.Contains(content, dep) {
+				ApplyFramework(path, project, name, framework)
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// findDotnetProjects returns the .csproj files below path, relative to it and
+// using forward slashes so they can be used directly in COPY instructions.
+func findDotnetProjects(path string) ([]string, error) {
+	var projects []string
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if rel != "." && (strings.HasPrefix(name, ".") || name == "bin" || name == "obj" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			if strings.Count(rel, string(filepath.Separator)) >= 3 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".csproj") {
+			projects = append(projects, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return projects, err
+}
+
+func dotnetAssemblyName(projectFile, content string) string {
+	re := regexp.MustCompile(`<AssemblyName>\s*([^<\s]+)\s*</AssemblyName>`)
+	if matches := re.FindStringSubmatch(content); len(matches) > 1 {
+		return matches[1]
+	}
+	return strings.TrimSuffix(filepath.Base(projectFile), ".csproj")
+}
@@ -0,0 +1,352 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"dockerizer-cli/internal/dockerfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFileNames are the file names docker compose looks for, in order
+var ComposeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// ExistingDockerfile is what dockerizer understands from a hand-written Dockerfile
+type ExistingDockerfile struct {
+	Path   string
+	Stages []DockerfileStage
+}
+
+// DockerfileStage represents a single FROM section of a Dockerfile
+type DockerfileStage struct {
+	Name       string
+	BaseImage  string
+	WorkDir    string
+	Ports      []string
+	Env        []string
+	Cmd        string
+	Entrypoint string
+}
+
+// ExistingCompose is what dockerizer understands from a hand-written compose file
+type ExistingCompose struct {
+	Path     string
+	Services map[string]ExistingService
+}
+
+// ExistingService represents a service of an existing compose file
+type ExistingService struct {
+	Image       string
+	Build       bool
+	Ports       []string
+	Environment []string
+}
+
+// imageLanguages maps official base images to the language they provide
+var imageLanguages = map[string]string{
+	"node":                            "Node.js",
+	"python":                          "Python",
+	"golang":                          "Go",
+	"php":                             "PHP",
+	"composer":                        "PHP",
+	"mcr.microsoft.com/dotnet/sdk":    ".NET",
+	"mcr.microsoft.com/dotnet/aspnet": ".NET",
+	"elixir":                          "Elixir",
+	"hexpm/elixir":                    "Elixir",
+	"denoland/deno":                   "Deno",
+	"oven/bun":                        "Bun",
+}
+
+// imageDatabases maps database images to dockerizer database types
+var imageDatabases = map[string]string{
+	"postgres": "postgres",
+	"mysql":    "mysql",
+	"mariadb":  "mysql",
+	"mongo":    "mongodb",
+}
+
+// ImportExisting parses an existing Dockerfile and compose file in path and
+// prefills the project with what they declare. Values found in the existing
+// files win over detected defaults, since they reflect how the project is
+// actually run today.
+func ImportExisting(path string, project *ProjectType) error {
+	dockerfilePath := filepath.Join(path, "Dockerfile")
+	if _, err := os.Stat(dockerfilePath); err == nil {
+		dockerfile, err := ParseDockerfile(dockerfilePath)
+		if err != nil {
+			return fmt.Errorf("failed to parse Dockerfile: %w", err)
+		}
+		project.ExistingDockerfile = dockerfile
+
+		if len(dockerfile.Stages) > 0 {
+			// The last stage is the one that ends up in the image
+			final := dockerfile.Stages[len(dockerfile.Stages)-1]
+			project.BaseImage = final.BaseImage
+			if len(final.Ports) > 0 {
+				project.Ports = final.Ports
+			}
+			project.Environment = mergeEnvironment(project.Environment, final.Env)
+
+			if project.Language == "" {
+				for _, stage := range dockerfile.Stages {
+					if language, ok := imageLanguages[imageName(stage.BaseImage)]; ok {
+						project.Language = language
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for _, name := range ComposeFileNames {
+		composePath := filepath.Join(path, name)
+		if _, err := os.Stat(composePath); err != nil {
+			continue
+		}
+
+		compose, err := ParseCompose(composePath)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		project.ExistingCompose = compose
+
+		for _, service := range compose.Services {
+			if service.Build {
+				if len(service.Ports) > 0 && project.ExistingDockerfile == nil {
+					project.Ports = containerPorts(service.Ports)
+				}
+				project.Environment = mergeEnvironment(project.Environment, service.Environment)
+				continue
+			}
+			if database, ok := imageDatabases[imageName(service.Image)]; ok && project.Database == "" {
+				project.Database = database
+			}
+		}
+		break
+	}
+
+	return nil
+}
+
+// Provides reports whether the compose file already has a service equivalent
+// to a generated one: the application itself (any service that is built from
+// source) or a service running the same image.
+func (c *ExistingCompose) Provides(name, image string) bool {
+	if _, ok := c.Services[name]; ok {
+		return true
+	}
+	for _, service := range c.Services {
+		if image == "" && name == "app" && service.Build {
+			return true
+		}
+		if image != "" && imageName(service.Image) == imageName(image) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDockerfile reads the stages of a Dockerfile
+func ParseDockerfile(path string) (*ExistingDockerfile, error) {
+	df, err := dockerfile.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := &ExistingDockerfile{Path: path}
+	for _, stage := range df.Stages {
+		summary := DockerfileStage{Name: stage.Name, BaseImage: stage.Image}
+		for _, instruction := range stage.Instructions {
+			fields := instruction.Fields()
+			switch instruction.Keyword {
+			case "WORKDIR":
+				summary.WorkDir = instruction.Args
+			case "EXPOSE":
+				for _, port := range fields {
+					summary.Ports = append(summary.Ports, strings.Split(port, "/")[0])
+				}
+			case "ENV":
+				summary.Env = append(summary.Env, parseEnvInstruction(instruction.Args)...)
+			case "CMD":
+				summary.Cmd = instruction.Args
+			case "ENTRYPOINT":
+				summary.Entrypoint = instruction.Args
+			}
+		}
+		existing.Stages = append(existing.Stages, summary)
+	}
+	return existing, nil
+}
+
+// parseEnvInstruction supports both `ENV KEY=value ...` and `ENV KEY value`
+func parseEnvInstruction(args string) []string {
+	words := shellWords(strings.ReplaceAll(args, "\\\n", " "))
+	if len(words) == 0 {
+		return nil
+	}
+	if !strings.Contains(words[0], "=") {
+		return []string{words[0] + "=" + strings.Join(words[1:], " ")}
+	}
+
+	var env []string
+	for _, word := range words {
+		if parts := strings.SplitN(word, "=", 2); len(parts) == 2 {
+			env = append(env, parts[0]+"="+parts[1])
+		}
+	}
+	return env
+}
+
+// shellWords splits arguments on unquoted whitespace and removes quotes and
+// backslash escapes, the way Docker reads ENV values
+func shellWords(args string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range args {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// ParseCompose reads the services of a compose file
+func ParseCompose(path string) (*ExistingCompose, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Services map[string]struct {
+			Image       string        `yaml:"image"`
+			Build       interface{}   `yaml:"build"`
+			Ports       []interface{} `yaml:"ports"`
+			Environment interface{}   `yaml:"environment"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	compose := &ExistingCompose{Path: path, Services: make(map[string]ExistingService)}
+	for name, service := range raw.Services {
+		compose.Services[name] = ExistingService{
+			Image:       service.Image,
+			Build:       service.Build != nil,
+			Ports:       composePorts(service.Ports),
+			Environment: composeEnvironment(service.Environment),
+		}
+	}
+	return compose, nil
+}
+
+// composeEnvironment normalizes the list and map forms of `environment`
+func composeEnvironment(value interface{}) []string {
+	var env []string
+	switch values := value.(type) {
+	case []interface{}:
+		for _, item := range values {
+			env = append(env, fmt.Sprint(item))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if values[key] == nil {
+				env = append(env, key)
+				continue
+			}
+			env = append(env, fmt.Sprintf("%s=%v", key, values[key]))
+		}
+	}
+	return env
+}
+
+// composePorts normalizes the short and long syntax of `ports`
+func composePorts(values []interface{}) []string {
+	var ports []string
+	for _, value := range values {
+		switch port := value.(type) {
+		case map[string]interface{}:
+			if target, ok := port["target"]; ok {
+				ports = append(ports, fmt.Sprint(target))
+			}
+		default:
+			ports = append(ports, fmt.Sprint(port))
+		}
+	}
+	return ports
+}
+
+// containerPorts returns the container side of compose port mappings
+func containerPorts(mappings []string) []string {
+	var ports []string
+	for _, mapping := range mappings {
+		parts := strings.Split(strings.Split(mapping, "/")[0], ":")
+		ports = append(ports, parts[len(parts)-1])
+	}
+	return ports
+}
+
+// mergeEnvironment appends variables that are not already set
+func mergeEnvironment(env []string, extra []string) []string {
+	seen := make(map[string]bool)
+	for _, variable := range env {
+		seen[strings.SplitN(variable, "=", 2)[0]] = true
+	}
+	for _, variable := range extra {
+		key := strings.SplitN(variable, "=", 2)[0]
+		if !seen[key] {
+			env = append(env, variable)
+			seen[key] = true
+		}
+	}
+	return env
+}
+
+// imageName strips the tag and digest from an image reference
+func imageName(image string) string {
+	if idx := strings.Index(image, "@"); idx != -1 {
+		image = image[:idx]
+	}
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		image = image[:idx]
+	}
+	return strings.TrimPrefix(image, "docker.io/library/")
+}
@@ -0,0 +1,24 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEnvInstruction(t *testing.T) {
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`A=1 B=2`, []string{"A=1", "B=2"}},
+		{`A="x y" B='$HOME' C=a\ b`, []string{"A=x y", "B=$HOME", "C=a b"}},
+		{"A=1 \\\n    B=\"two words\"", []string{"A=1", "B=two words"}},
+		{`GREETING hello "big" world`, []string{"GREETING=hello big world"}},
+		{`EMPTY=""`, []string{"EMPTY="}},
+	}
+	for _, tt := range tests {
+		if got := parseEnvInstruction(tt.args); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("parseEnvInstruction(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
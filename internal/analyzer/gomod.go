@@ -0,0 +1,239 @@
+package analyzer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GoModFile is what dockerizer understands from a go.mod or go.work file
+type GoModFile struct {
+	Module   string
+	Go       string
+	Requires []string
+	// Uses are the module directories of a go.work file, as written
+	Uses []string
+	// Replaces maps module paths to the local directory replacing them,
+	// as written. Replacements by other module versions are left out.
+	Replaces map[string]string
+}
+
+// ParseGoMod reads a go.mod or go.work file
+func ParseGoMod(path string) (*GoModFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mod := &GoModFile{Replaces: make(map[string]string)}
+	block := ""
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "//"); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Directives are either on one line or grouped in a ( ... ) block
+		verb := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			verb = fields[0]
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch verb {
+		case "module":
+			mod.Module = strings.Trim(fields[0], `"`)
+		case "go":
+			mod.Go = fields[0]
+		case "require":
+			mod.Requires = append(mod.Requires, strings.Trim(fields[0], `"`))
+		case "use":
+			mod.Uses = append(mod.Uses, strings.Trim(fields[0], `"`))
+		case "replace":
+			for i, field := range fields {
+				if field == "=>" && i+1 < len(fields) && isLocalModulePath(fields[i+1]) {
+					mod.Replaces[strings.Trim(fields[0], `"`)] = strings.Trim(fields[i+1], `"`)
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mod, nil
+}
+
+// FindGoWorkspace looks for a go.work file above path and for local replace
+// directives in its go.mod. It returns nil if the module builds on its own.
+func FindGoWorkspace(path string) (*Workspace, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	main, err := ParseGoMod(filepath.Join(abs, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Local modules by module path, with their absolute directory
+	local := make(map[string]string)
+	addReplaces := func(dir string, mod *GoModFile) {
+		for module, replacement := range mod.Replaces {
+			local[module] = filepath.Join(dir, replacement)
+		}
+	}
+
+	rootDir := ""
+	var rootFiles []string
+	uses := make(map[string]string)
+	if workDir := findGoWork(abs); workDir != "" {
+		work, err := ParseGoMod(filepath.Join(workDir, "go.work"))
+		if err != nil {
+			return nil, err
+		}
+		rootDir = workDir
+		rootFiles = []string{"go.work"}
+		if fileExists(filepath.Join(workDir, "go.work.sum")) {
+			rootFiles = append(rootFiles, "go.work.sum")
+		}
+
+		// In workspace mode every used module and its replaces take part
+		for _, use := range work.Uses {
+			dir := filepath.Join(workDir, use)
+			mod, err := ParseGoMod(filepath.Join(dir, "go.mod"))
+			if err != nil {
+				continue
+			}
+			local[mod.Module] = dir
+			uses[dir] = use
+			addReplaces(dir, mod)
+		}
+		addReplaces(workDir, work)
+	} else {
+		// Outside a workspace only the main module's replaces apply
+		addReplaces(abs, main)
+	}
+
+	// Collect the local modules the main module needs, dependencies first
+	var members []string
+	visited := map[string]bool{abs: true}
+	var visit func(dir string, mod *GoModFile)
+	visit = func(dir string, mod *GoModFile) {
+		for _, require := range mod.Requires {
+			depDir, ok := local[require]
+			if !ok || visited[depDir] {
+				continue
+			}
+			visited[depDir] = true
+			if dep, err := ParseGoMod(filepath.Join(depDir, "go.mod")); err == nil {
+				visit(depDir, dep)
+			}
+			members = append(members, depDir)
+		}
+	}
+	visit(abs, main)
+	members = append(members, abs)
+
+	if rootDir == "" {
+		if len(members) == 1 {
+			return nil, nil
+		}
+		rootDir = abs
+		for _, member := range members {
+			rootDir = commonDir(rootDir, member)
+		}
+	}
+
+	workspace := &Workspace{Manager: "go", Name: main.Module, RootFiles: rootFiles}
+	root, err := filepath.Rel(abs, rootDir)
+	if err != nil {
+		return nil, err
+	}
+	workspace.Root = filepath.ToSlash(root)
+	if pkg, err := filepath.Rel(rootDir, abs); err == nil && pkg != "." {
+		workspace.Package = filepath.ToSlash(pkg)
+	}
+
+	for _, member := range members {
+		rel, err := filepath.Rel(rootDir, member)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		workspace.Members = append(workspace.Members, filepath.ToSlash(rel))
+	}
+
+	// go.work must not list modules that are left out of the build context
+	for dir, use := range uses {
+		if !visited[dir] {
+			workspace.Excluded = append(workspace.Excluded, use)
+		}
+	}
+	sort.Strings(workspace.Excluded)
+
+	return workspace, nil
+}
+
+// findGoWork returns the directory of the go.work file governing dir, if any
+func findGoWork(dir string) string {
+	if os.Getenv("GOWORK") == "off" {
+		return ""
+	}
+	for ; ; dir = filepath.Dir(dir) {
+		if fileExists(filepath.Join(dir, "go.work")) {
+			return dir
+		}
+		if fileExists(filepath.Join(dir, ".git")) || filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// goRequires reports whether go.mod requires a module
+func goRequires(mod *GoModFile, module string) bool {
+	for _, require := range mod.Requires {
+		if require == module {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalModulePath reports whether a replacement is a directory in the
+// repository rather than another module version
+func isLocalModulePath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// commonDir returns the deepest directory containing both a and b
+func commonDir(a, b string) string {
+	for {
+		if rel, err := filepath.Rel(a, b); err == nil && !strings.HasPrefix(rel, "..") {
+			return a
+		}
+		if filepath.Dir(a) == a {
+			return a
+		}
+		a = filepath.Dir(a)
+	}
+}
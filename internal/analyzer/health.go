@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// healthRouteRe matches health endpoints declared as string literals,
+	// e.g. app.get("/health") or MapHealthChecks("/healthz")
+	healthRouteRe = regexp.MustCompile("[\"'`](/(?:api/)?(?:health|healthz|healthcheck|health-check|livez|readyz|ping|up))[\"'`]")
+
+	// healthSourceExtensions are the source files searched for routes
+	healthSourceExtensions = map[string]bool{
+		".js": true, ".mjs": true, ".cjs": true, ".ts": true,
+		".py": true, ".go": true, ".php": true, ".cs": true, ".ex": true, ".exs": true,
+	}
+
+	// healthSkippedDir never contain application routes
+	healthSkippedDir = map[string]bool{
+		"node_modules": true, "vendor": true, "venv": true, "__pycache__": true, "deps": true, "_build": true,
+		"bin": true, "obj": true, "dist": true, "build": true, "public": true, "tests": true, "test": true,
+	}
+)
+
+// DetectHealthCheck sets the HTTP path the container is probed on: a health
+// route found in the source, the framework's default from the catalog, or
+// the root of static sites. It stays empty when only the port can be
+// checked.
+func DetectHealthCheck(path string, project *ProjectType) {
+	if route := findHealthRoute(path); route != "" {
+		project.HealthCheck = route
+		return
+	}
+	if language, err := FindLanguageConfig(project.Language); err == nil {
+		if framework, ok := language.Frameworks[project.Framework]; ok && framework.HealthCheck != "" {
+			project.HealthCheck = framework.HealthCheck
+			return
+		}
+	}
+	if project.StaticSite {
+		project.HealthCheck = "/"
+	}
+}
+
+// findHealthRoute returns the first health route declared in the project's
+// source, preferring the dedicated names over ping and up
+func findHealthRoute(path string) string {
+	found := make(map[string]bool)
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if rel != "." && (strings.HasPrefix(info.Name(), ".") || healthSkippedDir[info.Name()] || strings.Count(rel, string(filepath.Separator)) >= 4) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !healthSourceExtensions[filepath.Ext(file)] {
+			return nil
+		}
+		if data, err := ioutil.ReadFile(file); err == nil {
+			for _, match := range healthRouteRe.FindAllStringSubmatch(string(data), -1) {
+				found[match[1]] = true
+			}
+		}
+		return nil
+	})
+
+	for _, name := range []string{"health", "healthz", "healthcheck", "health-check", "livez", "readyz", "ping", "up"} {
+		for _, route := range []string{"/" + name, "/api/" + name} {
+			if found[route] {
+				return route
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,209 @@
+package analyzer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HerokuApp is what dockerizer understands from a Procfile and app.json
+type HerokuApp struct {
+	Processes  []Process
+	Env        []string
+	Addons     []string
+	Buildpacks []string
+}
+
+// herokuAddons maps Heroku add-ons to the dockerizer service replacing them
+// and the variable the add-on injects into the app
+var herokuAddons = map[string]struct {
+	Service string
+	EnvVar  string
+}{
+	"heroku-postgresql": {"postgres", "DATABASE_URL"},
+	"heroku-redis":      {"redis", "REDIS_URL"},
+	"rediscloud":        {"redis", "REDISCLOUD_URL"},
+	"redistogo":         {"redis", "REDISTOGO_URL"},
+	"jawsdb":            {"mysql", "JAWSDB_URL"},
+	"jawsdb-maria":      {"mysql", "JAWSDB_MARIA_URL"},
+	"cleardb":           {"mysql", "CLEARDB_DATABASE_URL"},
+	"mongolab":          {"mongodb", "MONGODB_URI"},
+	"cloudamqp":         {"rabbitmq", "CLOUDAMQP_URL"},
+}
+
+// herokuBuildpacks maps official buildpacks to the language they build
+var herokuBuildpacks = map[string]string{
+	"heroku/nodejs": "Node.js",
+	"heroku/python": "Python",
+	"heroku/go":     "Go",
+	"heroku/php":    "PHP",
+}
+
+// ImportHeroku reads a Procfile and app.json, if present, and maps their
+// processes, add-ons, buildpacks and environment onto the project
+func ImportHeroku(path string, project *ProjectType) error {
+	app, err := ParseHeroku(path)
+	if err != nil || app == nil {
+		return err
+	}
+	project.Heroku = app
+
+	for _, process := range app.Processes {
+		switch process.Name {
+		case "web":
+			// The web process is the app service itself
+			project.Command = process.Command
+		case "release":
+			// The release phase runs once per deployment, e.g. migrations
+			process.OneOff = true
+			project.Processes = replaceProcess(project.Processes, process)
+		default:
+			project.Processes = replaceProcess(project.Processes, process)
+		}
+	}
+
+	project.Environment = mergeEnvironment(project.Environment, app.Env)
+
+	for _, addon := range app.Addons {
+		mapping, ok := herokuAddons[addon]
+		if !ok {
+			continue
+		}
+		switch mapping.Service {
+		case "postgres", "mysql", "mongodb":
+			if project.Database == "" {
+				project.Database = mapping.Service
+			}
+		default:
+			project.AdditionalServices = appendUnique(project.AdditionalServices, mapping.Service)
+		}
+		if project.ServiceURLs == nil {
+			project.ServiceURLs = make(map[string]string)
+		}
+		project.ServiceURLs[mapping.EnvVar] = mapping.Service
+	}
+
+	if project.Language == "" {
+		for _, buildpack := range app.Buildpacks {
+			if language, ok := herokuBuildpacks[buildpack]; ok {
+				project.Language = language
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseHeroku reads the Procfile and app.json in path. It returns nil when
+// neither file exists.
+func ParseHeroku(path string) (*HerokuApp, error) {
+	app := &HerokuApp{}
+	found := false
+
+	if file, err := os.Open(filepath.Join(path, "Procfile")); err == nil {
+		found = true
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			app.Processes = append(app.Processes, Process{
+				Name:    strings.TrimSpace(parts[0]),
+				Command: strings.TrimSpace(parts[1]),
+			})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read Procfile: %w", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, "app.json"))
+	if err == nil {
+		found = true
+
+		var manifest struct {
+			Env        map[string]json.RawMessage `json:"env"`
+			Addons     []json.RawMessage          `json:"addons"`
+			Buildpacks []struct {
+				URL string `json:"url"`
+			} `json:"buildpacks"`
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse app.json: %w", err)
+		}
+
+		names := make([]string, 0, len(manifest.Env))
+		for name := range manifest.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// Variables are either plain strings or objects with a value.
+			// Generated secrets and variables without a default are left
+			// to the .env file.
+			var value string
+			if err := json.Unmarshal(manifest.Env[name], &value); err != nil {
+				var variable struct {
+					Value string `json:"value"`
+				}
+				if err := json.Unmarshal(manifest.Env[name], &variable); err != nil {
+					continue
+				}
+				value = variable.Value
+			}
+			if value != "" {
+				app.Env = append(app.Env, name+"="+value)
+			}
+		}
+
+		for _, raw := range manifest.Addons {
+			// Add-ons are either "plan" strings or objects with a plan
+			var plan string
+			if err := json.Unmarshal(raw, &plan); err != nil {
+				var addon struct {
+					Plan string `json:"plan"`
+				}
+				if err := json.Unmarshal(raw, &addon); err != nil {
+					continue
+				}
+				plan = addon.Plan
+			}
+			app.Addons = append(app.Addons, strings.SplitN(plan, ":", 2)[0])
+		}
+
+		for _, buildpack := range manifest.Buildpacks {
+			app.Buildpacks = append(app.Buildpacks, buildpack.URL)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return app, nil
+}
+
+// replaceProcess adds a process, replacing a detected one with the same name
+func replaceProcess(processes []Process, process Process) []Process {
+	for i, existing := range processes {
+		if existing.Name == process.Name {
+			processes[i] = process
+			return processes
+		}
+	}
+	return append(processes, process)
+}
@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// denoEntryCandidates are the conventional entry modules of Deno projects
+var denoEntryCandidates = []string{"main.ts", "mod.ts", "server.ts", "app.ts", "index.ts", "src/main.ts", "src/index.ts", "main.js"}
+
+// bunEntryCandidates are the conventional entry modules of Bun projects
+var bunEntryCandidates = []string{"src/index.ts", "index.ts", "src/server.ts", "server.ts", "src/index.js", "index.js"}
+
+func detectDenoFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	configFile := "deno.json"
+	data, err := ioutil.ReadFile(filepath.Join(path, configFile))
+	if err != nil {
+		configFile = "deno.jsonc"
+		data, err = ioutil.ReadFile(filepath.Join(path, configFile))
+		if err != nil {
+			return err
+		}
+	}
+	content := string(data)
+
+	// deno.json(c) may contain comments, so the start task is read with a regexp
+	var taskFlags []string
+	re := regexp.MustCompile(`"start"\s*:\s*"([^"]+)"`)
+	if matches := re.FindStringSubmatch(content); len(matches) > 1 {
+		taskFlags, project.EntryPoint = parseDenoRunCommand(matches[1])
+	}
+	if project.EntryPoint == "" {
+		project.EntryPoint = firstExisting(path, denoEntryCandidates)
+	}
+
+	// Imports may live in the entry module instead of the import map
+	if project.EntryPoint != "" {
+		if entry, err := ioutil.ReadFile(filepath.Join(path, project.EntryPoint)); err == nil {
+			content += string(entry)
+		}
+	}
+
+	if version, err := detectDenoVersion(path); err == nil {
+		project.Version = version
+	}
+
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
+		for _, dep := range framework.Dependencies {
+			if strings.Contains(content, dep) {
+				ApplyFramework(path, project, name, framework)
+				if len(taskFlags) > 0 {
+					project.RuntimeFlags = taskFlags
+				}
+				return nil
+			}
+		}
+	}
+
+	// Deno.serve listens on port 8000 unless told otherwise
+	project.Ports = []string{"8000"}
+	project.RuntimeFlags = taskFlags
+	return nil
+}
+
+// parseDenoRunCommand extracts the permission flags and entry module from a
+// task such as "deno run --allow-net --allow-env main.ts".
+func parseDenoRunCommand(command string) ([]string, string) {
+	fields := strings.Fields(command)
+	var flags []string
+	for i, field := range fields {
+		if i < 2 && (field == "deno" || field == "run") {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			if field != "--watch" {
+				flags = append(flags, field)
+			}
+			continue
+		}
+		// Everything after the module is passed to the program itself
+		return flags, field
+	}
+	return flags, ""
+}
+
+func detectBunFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	data, err := ioutil.ReadFile(filepath.Join(path, "package.json"))
+	if err != nil {
+		return err
+	}
+
+	var packageJSON struct {
+		Main            string            `json:"main"`
+		Module          string            `json:"module"`
+		Scripts         map[string]string `json:"scripts"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return err
+	}
+
+	// Work out which file `bun run` should execute
+	start := strings.Fields(packageJSON.Scripts["start"])
+	startsBun := len(start) > 1 && start[0] == "bun"
+	switch {
+	case packageJSON.Module != "":
+		project.EntryPoint = packageJSON.Module
+	case packageJSON.Main != "":
+		project.EntryPoint = packageJSON.Main
+	case startsBun:
+		project.EntryPoint = start[len(start)-1]
+	default:
+		project.EntryPoint = firstExisting(path, bunEntryCandidates)
+	}
+
+	if version, err := detectBunVersion(path); err == nil {
+		project.Version = version
+	}
+
+	deps := make(map[string]string)
+	for k, v := range packageJSON.Dependencies {
+		deps[k] = v
+	}
+	for k, v := range packageJSON.DevDependencies {
+		deps[k] = v
+	}
+
+	for _, name := range config.FrameworkNames() {
+		framework := config.Frameworks[name]
+		for _, dep := range framework.Dependencies {
+			if _, ok := deps[dep]; ok {
+				ApplyFramework(path, project, name, framework)
+				return nil
+			}
+		}
+	}
+
+	// A bun lockfile alone only shows bun installs the packages. Apps of
+	// Node.js frameworks such as Next.js are left to the Node.js detection,
+	// the project is Bun's when it runs a module with bun.
+	isTypeScript := strings.HasSuffix(project.EntryPoint, ".ts") || strings.HasSuffix(project.EntryPoint, ".tsx")
+	if !startsBun && packageJSON.Module == "" && !isTypeScript {
+		project.EntryPoint, project.Version = "", ""
+		return fmt.Errorf("no Bun framework or entry module")
+	}
+	return nil
+}
+
+// firstExisting returns the first candidate that exists below path
+func firstExisting(path string, candidates []string) string {
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(path, candidate)); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	queueConnectionRe = regexp.MustCompile(`(?m)^\s*QUEUE_CONNECTION\s*=\s*["']?([\w-]+)`)
+	queueDefaultRe    = regexp.MustCompile(`env\(\s*["']QUEUE_CONNECTION["']\s*,\s*["']([\w-]+)["']`)
+	scheduleRe        = regexp.MustCompile(`\$schedule->(command|job|call|exec)\(|Schedule::(command|job|call|exec)\(`)
+)
+
+// detectLaravelProcesses adds the queue worker (or Horizon) and scheduler
+// processes a Laravel application needs
+func detectLaravelProcesses(path string, project *ProjectType) {
+	connection := laravelQueueConnection(path)
+
+	var composer ComposerJSON
+	if data, err := ioutil.ReadFile(filepath.Join(path, "composer.json")); err == nil {
+		json.Unmarshal(data, &composer)
+	}
+	_, hasHorizon := composer.Require["laravel/horizon"]
+
+	switch {
+	case hasHorizon:
+		// Horizon supervises the Redis queue workers itself
+		project.Processes = append(project.Processes, Process{Name: "horizon", Command: "php artisan horizon"})
+		project.Broker = "redis"
+		project.AdditionalServices = appendUnique(project.AdditionalServices, "redis")
+	case connection != "" && connection != "sync" && connection != "null":
+		project.Processes = append(project.Processes, Process{
+			Name:    "queue",
+			Command: "php artisan queue:work " + connection + " --sleep=3 --tries=3 --max-time=3600",
+		})
+		switch connection {
+		case "redis":
+			project.Broker = "redis"
+			project.AdditionalServices = appendUnique(project.AdditionalServices, "redis")
+		case "rabbitmq":
+			project.Broker = "rabbitmq"
+			project.AdditionalServices = appendUnique(project.AdditionalServices, "rabbitmq")
+		}
+	}
+
+	// Laravel 11 schedules in routes/console.php, older versions in the console kernel
+	for _, file := range []string{"routes/console.php", "app/Console/Kernel.php"} {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err == nil && scheduleRe.Match(data) {
+			project.Processes = append(project.Processes, Process{Name: "scheduler", Command: "php artisan schedule:work"})
+			break
+		}
+	}
+}
+
+// laravelQueueConnection reads QUEUE_CONNECTION from the environment files,
+// falling back to the default in config/queue.php
+func laravelQueueConnection(path string) string {
+	for _, file := range []string{".env", ".env.example"} {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		if matches := queueConnectionRe.FindSubmatch(data); len(matches) > 1 {
+			return strings.ToLower(string(matches[1]))
+		}
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(path, "config", "queue.php")); err == nil {
+		if matches := queueDefaultRe.FindSubmatch(data); len(matches) > 1 {
+			return strings.ToLower(string(matches[1]))
+		}
+	}
+	return ""
+}
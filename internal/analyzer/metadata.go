@@ -0,0 +1,300 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ImageMetadata describes the project in the image's OCI labels
+type ImageMetadata struct {
+	Title       string
+	Description string
+	Source      string
+	Version     string
+	Licenses    string
+}
+
+var (
+	// pyprojectSectionRe finds the [project] and [tool.poetry] tables
+	pyprojectSectionRe = regexp.MustCompile(`(?m)^\[(project|tool\.poetry)\]\s*$`)
+	pyprojectFieldRe   = regexp.MustCompile(`(?m)^(name|description|version)\s*=\s*["']([^"']*)["']`)
+	pyprojectLicenseRe = regexp.MustCompile(`(?m)^license\s*=\s*(?:\{\s*text\s*=\s*)?["']([^"']+)["']`)
+	pyprojectURLRe     = regexp.MustCompile(`(?mi)^(?:repository|source|homepage)\s*=\s*["']([^"']+)["']`)
+	mixVersionRe       = regexp.MustCompile(`version:\s*"([^"]+)"`)
+	csprojFieldRe      = regexp.MustCompile(`<(Version|Description|PackageLicenseExpression|RepositoryUrl)>([^<]+)</`)
+
+	// gitRemoteRe matches the url of the origin remote in .git/config
+	gitRemoteRe = regexp.MustCompile(`(?s)\[remote "origin"\][^\[]*?url\s*=\s*(\S+)`)
+	// scpRemoteRe matches remotes such as git@github.com:org/repo.git
+	scpRemoteRe = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+)
+
+// licenseTexts identify common licenses by phrases of their text, checked
+// in order
+var licenseTexts = []struct {
+	spdx    string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 2.1"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"Unlicense", []string{"This is free and unencumbered software"}},
+}
+
+// licenseFiles are checked for the license text
+var licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING"}
+
+// DetectMetadata collects the image labels from the project's manifest
+// (package.json, composer.json, pyproject.toml, go.mod, mix.exs or the
+// .csproj), its LICENSE file and the origin remote of its git repository
+func DetectMetadata(path string, project *ProjectType) {
+	metadata := &ImageMetadata{}
+	readManifestMetadata(path, project, metadata)
+
+	if metadata.Title == "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			metadata.Title = filepath.Base(abs)
+		}
+	}
+	if metadata.Licenses == "" {
+		metadata.Licenses = detectLicense(path)
+	}
+	if source := gitRemoteURL(path); source != "" {
+		metadata.Source = source
+	} else {
+		metadata.Source = normalizeRepositoryURL(metadata.Source)
+	}
+	project.Metadata = metadata
+}
+
+// readManifestMetadata reads name, description, version, license and
+// repository from the language's manifest
+func readManifestMetadata(path string, project *ProjectType, metadata *ImageMetadata) {
+	var manifest struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Version     string          `json:"version"`
+		License     json.RawMessage `json:"license"`
+		Homepage    string          `json:"homepage"`
+		Repository  json.RawMessage `json:"repository"`
+		Support     struct {
+			Source string `json:"source"`
+		} `json:"support"`
+	}
+	for _, file := range []string{"package.json", "composer.json", "deno.json"} {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil || json.Unmarshal(data, &manifest) != nil {
+			continue
+		}
+		metadata.Title = manifest.Name
+		metadata.Description = manifest.Description
+		metadata.Version = manifest.Version
+		metadata.Licenses = jsonLicense(manifest.License)
+		metadata.Source = jsonRepository(manifest.Repository)
+		if metadata.Source == "" {
+			metadata.Source = manifest.Support.Source
+		}
+		return
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(path, "pyproject.toml")); err == nil {
+		content := string(data)
+		if loc := pyprojectSectionRe.FindStringIndex(content); loc != nil {
+			section := content[loc[1]:]
+			if next := strings.Index(section, "\n["); next != -1 {
+				section = section[:next]
+			}
+			for _, match := range pyprojectFieldRe.FindAllStringSubmatch(section, -1) {
+				switch match[1] {
+				case "name":
+					metadata.Title = match[2]
+				case "description":
+					metadata.Description = match[2]
+				case "version":
+					metadata.Version = match[2]
+				}
+			}
+			if match := pyprojectLicenseRe.FindStringSubmatch(section); match != nil {
+				metadata.Licenses = match[1]
+			}
+		}
+		if match := pyprojectURLRe.FindStringSubmatch(content); match != nil {
+			metadata.Source = match[1]
+		}
+		return
+	}
+
+	if mod, err := ParseGoMod(filepath.Join(path, "go.mod")); err == nil {
+		metadata.Title = mod.Module[strings.LastIndex(mod.Module, "/")+1:]
+		if strings.Count(mod.Module, "/") >= 2 && strings.Contains(mod.Module[:strings.Index(mod.Module, "/")], ".") {
+			metadata.Source = "https://" + mod.Module
+		}
+		return
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(path, "mix.exs")); err == nil {
+		metadata.Title = project.AppName
+		if match := mixVersionRe.FindStringSubmatch(string(data)); match != nil {
+			metadata.Version = match[1]
+		}
+		return
+	}
+
+	if project.ProjectFile != "" {
+		if data, err := ioutil.ReadFile(filepath.Join(path, project.ProjectFile)); err == nil {
+			metadata.Title = project.AppName
+			for _, match := range csprojFieldRe.FindAllStringSubmatch(string(data), -1) {
+				switch match[1] {
+				case "Version":
+					metadata.Version = match[2]
+				case "Description":
+					metadata.Description = match[2]
+				case "PackageLicenseExpression":
+					metadata.Licenses = match[2]
+				case "RepositoryUrl":
+					metadata.Source = match[2]
+				}
+			}
+		}
+	}
+}
+
+// jsonLicense reads a license given as an SPDX string or, like Composer, as
+// a list of them
+func jsonLicense(raw json.RawMessage) string {
+	var license string
+	if json.Unmarshal(raw, &license) == nil {
+		return license
+	}
+	var licenses []string
+	if json.Unmarshal(raw, &licenses) == nil {
+		return strings.Join(licenses, " OR ")
+	}
+	return ""
+}
+
+// jsonRepository reads package.json's repository, a URL or {"url": ...}
+func jsonRepository(raw json.RawMessage) string {
+	var repository string
+	if json.Unmarshal(raw, &repository) == nil {
+		// Shorthands such as "github:org/repo" or "org/repo"
+		if rest, ok := strings.CutPrefix(repository, "github:"); ok {
+			return "https://github.com/" + rest
+		}
+		if !strings.Contains(repository, ":") && strings.Count(repository, "/") == 1 {
+			return "https://github.com/" + repository
+		}
+		return repository
+	}
+	var object struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(raw, &object) == nil {
+		return object.URL
+	}
+	return ""
+}
+
+// detectLicense identifies the SPDX license of the project's LICENSE file
+func detectLicense(path string) string {
+	for _, file := range licenseFiles {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		text := strings.Join(strings.Fields(string(data)), " ")
+		for _, license := range licenseTexts {
+			matched := true
+			for _, phrase := range license.phrases {
+				if !strings.Contains(text, phrase) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return license.spdx
+			}
+		}
+	}
+	return ""
+}
+
+// gitRemoteURL returns the web URL of the origin remote of the git
+// repository containing path
+func gitRemoteURL(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			// Worktrees and submodules point to the real git directory
+			if !info.IsDir() {
+				data, err := ioutil.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+				if common, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+					gitDir = filepath.Join(gitDir, strings.TrimSpace(string(common)))
+				}
+			}
+			data, err := ioutil.ReadFile(filepath.Join(gitDir, "config"))
+			if err != nil {
+				return ""
+			}
+			if match := gitRemoteRe.FindStringSubmatch(string(data)); match != nil {
+				return normalizeRepositoryURL(match[1])
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// normalizeRepositoryURL turns git remotes into web URLs without
+// credentials or the .git suffix
+func normalizeRepositoryURL(url string) string {
+	url = strings.TrimPrefix(url, "git+")
+	if match := scpRemoteRe.FindStringSubmatch(url); match != nil && !strings.Contains(url, "://") {
+		url = "https://" + match[1] + "/" + match[2]
+	}
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return strings.TrimSuffix(url, ".git")
+	}
+
+	host, repo, _ := strings.Cut(rest, "/")
+	if at := strings.LastIndex(host, "@"); at != -1 {
+		host = host[at+1:]
+	}
+	switch scheme {
+	case "ssh":
+		// The ssh port is not the web port
+		host, _, _ = strings.Cut(host, ":")
+		scheme = "https"
+	case "git":
+		scheme = "https"
+	}
+	return scheme + "://" + host + "/" + strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NativeBuildConfig lists the dependencies of a language that compile
+// native code during the install, e.g. when no prebuilt binary or wheel
+// matches the target platform
+type NativeBuildConfig struct {
+	// Packages is the toolchain installed in the build stage
+	Packages     []string                    `yaml:"packages"`
+	Dependencies map[string]NativeDependency `yaml:"dependencies"`
+}
+
+// NativeDependency is what a native dependency needs besides the toolchain
+type NativeDependency struct {
+	// Build are the headers it compiles against
+	Build []string `yaml:"build,omitempty"`
+	// Runtime are the shared libraries it loads
+	Runtime []string `yaml:"runtime,omitempty"`
+}
+
+// requirementNameRe matches the package name of a requirements.txt line
+var requirementNameRe = regexp.MustCompile(`(?m)^\s*([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// DetectNativeModules records the project's dependencies that are listed
+// under native_build in the catalog
+func DetectNativeModules(path string, project *ProjectType) {
+	language, err := FindLanguageConfig(project.Language)
+	if err != nil || len(language.NativeBuild.Dependencies) == 0 {
+		return
+	}
+
+	var deps []string
+	switch project.Language {
+	case "Node.js":
+		deps = packageDependencies(filepath.Join(path, "package.json"))
+		if workspace := project.Workspace; workspace != nil {
+			root := filepath.Join(path, workspace.Root)
+			deps = append(deps, packageDependencies(filepath.Join(root, "package.json"))...)
+			for _, member := range workspace.Members {
+				deps = append(deps, packageDependencies(filepath.Join(root, member, "package.json"))...)
+			}
+		}
+	case "Python":
+		if data, err := ioutil.ReadFile(filepath.Join(path, "requirements.txt")); err == nil {
+			for _, match := range requirementNameRe.FindAllStringSubmatch(string(data), -1) {
+				deps = append(deps, strings.ToLower(match[1]))
+			}
+		}
+	}
+
+	project.NativeModules = nil
+	for _, dep := range deps {
+		if _, ok := language.NativeBuild.Dependencies[dep]; ok {
+			project.NativeModules = appendUnique(project.NativeModules, dep)
+		}
+	}
+	sort.Strings(project.NativeModules)
+}
+
+// packageDependencies returns the names of all dependencies in a
+// package.json, including the development ones the build stage installs
+func packageDependencies(file string) []string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	if json.Unmarshal(data, &manifest) != nil {
+		return nil
+	}
+	var deps []string
+	for _, group := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.OptionalDependencies} {
+		for dep := range group {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
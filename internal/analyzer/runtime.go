@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RuntimeConfig lists the images a language's production stage can be
+// based on
+type RuntimeConfig struct {
+	Default string                   `yaml:"default"`
+	Flavors map[string]RuntimeFlavor `yaml:"flavors"`
+}
+
+// RuntimeFlavor is a base image for the production stage and what the
+// generator has to add to it
+type RuntimeFlavor struct {
+	Name  string `yaml:"-"`
+	Image string `yaml:"image"`
+	// Builder replaces the build stage image when the runtime needs a
+	// matching interpreter version
+	Builder string `yaml:"builder,omitempty"`
+	// Shell reports whether RUN instructions and shell commands work
+	Shell bool `yaml:"shell"`
+	// User is the unprivileged user the image ships, by name or UID
+	User string `yaml:"user,omitempty"`
+	// Packages are installed with the image's package manager, e.g. CA
+	// certificates and timezone data
+	Packages []string `yaml:"packages,omitempty"`
+	// Copy lists files copied from the build stage to the same path
+	Copy []string `yaml:"copy,omitempty"`
+	Env  []string `yaml:"env,omitempty"`
+	// Entrypoint is the interpreter the image runs its command with. CMD
+	// only holds its arguments.
+	Entrypoint []string `yaml:"entrypoint,omitempty"`
+	// Interpreter is the interpreter's path for health probes when it is
+	// not on the PATH
+	Interpreter string `yaml:"interpreter,omitempty"`
+	// ModuleFlag runs commands that are not the interpreter itself as a
+	// module, e.g. python -m gunicorn
+	ModuleFlag string `yaml:"module_flag,omitempty"`
+}
+
+// FlavorNames returns the runtime flavors of the language in order
+func (c *LanguageConfig) FlavorNames() []string {
+	names := make([]string, 0, len(c.Runtime.Flavors))
+	for name := range c.Runtime.Flavors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RuntimeFlavor returns the named runtime flavor, or the language's default
+// when name is empty. Languages without flavors return nil.
+func (c *LanguageConfig) RuntimeFlavor(name string) (*RuntimeFlavor, error) {
+	if len(c.Runtime.Flavors) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("%s has no runtime flavors", c.Name)
+		}
+		return nil, nil
+	}
+	if name == "" {
+		name = c.Runtime.Default
+	}
+	flavor, ok := c.Runtime.Flavors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s runtime flavor %q (available: %s)", c.Name, name, strings.Join(c.FlavorNames(), ", "))
+	}
+	flavor.Name = name
+	return &flavor, nil
+}
@@ -0,0 +1,152 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// BuildSecret is a credential the build needs to install private packages.
+// It is passed with BuildKit secrets so it never ends up in an image layer.
+type BuildSecret struct {
+	ID string
+	// File is the secret's file relative to the project, or Env the host
+	// environment variable holding it
+	File string
+	Env  string
+	// Target is where the secret is mounted, /run/secrets/<id> if empty
+	Target string
+	// Variable is set from the secret for the install command, if any
+	Variable string
+}
+
+var (
+	npmAuthRe        = regexp.MustCompile(`(?m)(_authToken|_auth|_password)\s*=`)
+	envReferenceRe   = regexp.MustCompile(`\$\{(\w+)\}`)
+	pipIndexRe       = regexp.MustCompile(`(?m)^\s*(--index-url|--extra-index-url|-i)[\s=]+(\S+)`)
+	urlCredentialsRe = regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`)
+	goPrivateRe      = regexp.MustCompile(`(?m)^\s*(?:export\s+)?GOPRIVATE=["']?([^"'\s]+)`)
+)
+
+// DetectSecrets finds the private registry credentials the build needs:
+// npm tokens in .npmrc, private Python indexes, GOPRIVATE modules and
+// Composer's auth.json. It returns warnings about credentials that would
+// still be copied into the image.
+func DetectSecrets(path string, project *ProjectType) []string {
+	var warnings []string
+
+	switch project.Language {
+	case "Node.js":
+		// In a workspace the .npmrc usually lives at the root
+		candidates := []string{".npmrc"}
+		if project.Workspace != nil && project.Workspace.Root != "." {
+			candidates = append(candidates, filepath.Join(project.Workspace.Root, ".npmrc"))
+		}
+		for _, file := range candidates {
+			data, err := ioutil.ReadFile(filepath.Join(path, file))
+			if err != nil || !npmAuthRe.Match(data) {
+				continue
+			}
+			project.Secrets = append(project.Secrets, BuildSecret{ID: "npmrc", File: filepath.ToSlash(file), Target: "/root/.npmrc"})
+			addEnvSecrets(project, string(data))
+			if project.Workspace != nil {
+				project.Workspace.RootFiles = removeString(project.Workspace.RootFiles, ".npmrc")
+			}
+			break
+		}
+
+	case "Python":
+		for _, file := range []string{"pip.conf", "pip.ini"} {
+			if _, err := os.Stat(filepath.Join(path, file)); err == nil {
+				project.Secrets = append(project.Secrets, BuildSecret{ID: "pip_conf", File: file, Target: "/etc/pip.conf"})
+				break
+			}
+		}
+
+		// pip expands ${VAR} in requirements files, so index URLs can take
+		// their credentials from the environment
+		files, _ := filepath.Glob(filepath.Join(path, "requirements*.txt"))
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, match := range pipIndexRe.FindAllStringSubmatch(string(data), -1) {
+				if urlCredentialsRe.MatchString(match[2]) && !envReferenceRe.MatchString(match[2]) {
+					warnings = append(warnings, fmt.Sprintf("%s contains index credentials that will be copied into the image; use ${VARIABLE} references instead", filepath.Base(file)))
+					continue
+				}
+				addEnvSecrets(project, match[2])
+			}
+		}
+
+	case "Go":
+		project.GoPrivate = os.Getenv("GOPRIVATE")
+		for _, file := range []string{".env", ".envrc"} {
+			if project.GoPrivate != "" {
+				break
+			}
+			if data, err := ioutil.ReadFile(filepath.Join(path, file)); err == nil {
+				if matches := goPrivateRe.FindStringSubmatch(string(data)); len(matches) > 1 {
+					project.GoPrivate = matches[1]
+				}
+			}
+		}
+		if project.GoPrivate != "" {
+			// Private modules are fetched with git using the host's credentials
+			project.Secrets = append(project.Secrets, BuildSecret{ID: "git_credentials", File: "${HOME}/.git-credentials", Target: "/root/.git-credentials"})
+		}
+
+	case "PHP":
+		if _, err := os.Stat(filepath.Join(path, "auth.json")); err == nil {
+			project.Secrets = append(project.Secrets, BuildSecret{ID: "composer_auth", File: "auth.json", Variable: "COMPOSER_AUTH"})
+		} else if os.Getenv("COMPOSER_AUTH") != "" {
+			project.Secrets = append(project.Secrets, BuildSecret{ID: "composer_auth", Env: "COMPOSER_AUTH", Variable: "COMPOSER_AUTH"})
+		}
+	}
+
+	return warnings
+}
+
+// SecretFiles returns the secret files that are part of the project and
+// must be kept out of the build context
+func (p *ProjectType) SecretFiles() []string {
+	var files []string
+	for _, secret := range p.Secrets {
+		if secret.File != "" && !strings.HasPrefix(secret.File, "$") {
+			files = append(files, secret.File)
+		}
+	}
+	return files
+}
+
+// addEnvSecrets adds a secret for every ${VARIABLE} referenced in content
+func addEnvSecrets(project *ProjectType, content string) {
+	for _, match := range envReferenceRe.FindAllStringSubmatch(content, -1) {
+		id := strings.ToLower(match[1])
+		exists := false
+		for _, secret := range project.Secrets {
+			if secret.ID == id {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			project.Secrets = append(project.Secrets, BuildSecret{ID: id, Env: match[1], Variable: match[1]})
+		}
+	}
+}
+
+// removeString returns values without value
+func removeString(values []string, value string) []string {
+	var result []string
+	for _, existing := range values {
+		if existing != value {
+			result = append(result, existing)
+		}
+	}
+	return result
+}
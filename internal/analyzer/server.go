@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AppServerConfig is how a framework's app runs in production: loaded by
+// gunicorn, in uvicorn workers for ASGI apps
+type AppServerConfig struct {
+	// App is loaded when the source does not show the application, e.g.
+	// main:app
+	App  string `yaml:"app"`
+	ASGI bool   `yaml:"asgi,omitempty"`
+	// Factory is the class or function the application is created with
+	Factory string `yaml:"factory,omitempty"`
+	// Files are searched for the application, in order
+	Files []string `yaml:"files,omitempty"`
+	// ASGIPackages switch to ASGI when the requirements list one of them
+	ASGIPackages []string `yaml:"asgi_packages,omitempty"`
+}
+
+// AppServer is how the production image serves a Python web app
+type AppServer struct {
+	// App is the WSGI or ASGI application, e.g. mysite.wsgi:application
+	App  string
+	ASGI bool
+	// Packages are the server packages missing from the requirements
+	Packages []string
+}
+
+// djangoSettingsRe matches the settings module manage.py sets
+var djangoSettingsRe = regexp.MustCompile(`DJANGO_SETTINGS_MODULE["']\s*,\s*["']([\w.]+)\.settings["']`)
+
+// DetectAppServer sets the application gunicorn serves in the production
+// image and the server packages to install with the requirements
+func DetectAppServer(path string, project *ProjectType) {
+	project.AppServer = nil
+	language, err := FindLanguageConfig(project.Language)
+	if err != nil {
+		return
+	}
+	config := language.Frameworks[project.Framework].Server
+	if config == nil {
+		return
+	}
+
+	var requirements []string
+	if data, err := ioutil.ReadFile(filepath.Join(path, "requirements.txt")); err == nil {
+		for _, match := range requirementNameRe.FindAllStringSubmatch(string(data), -1) {
+			requirements = append(requirements, strings.ToLower(match[1]))
+		}
+	}
+
+	server := &AppServer{App: config.App, ASGI: config.ASGI}
+	for _, pkg := range config.ASGIPackages {
+		if containsString(requirements, pkg) {
+			server.ASGI = true
+		}
+	}
+
+	if project.Framework == "django" {
+		// The project package holds settings.py next to wsgi.py and asgi.py,
+		// and the containers get the settings module manage.py defaults to
+		if data, err := ioutil.ReadFile(filepath.Join(path, "manage.py")); err == nil {
+			if match := djangoSettingsRe.FindSubmatch(data); match != nil {
+				server.App = string(match[1]) + ".wsgi:application"
+				project.Environment = mergeEnvironment(project.Environment,
+					[]string{"DJANGO_SETTINGS_MODULE=" + string(match[1]) + ".settings"})
+			}
+		}
+		if server.ASGI {
+			server.App = strings.Replace(server.App, ".wsgi:", ".asgi:", 1)
+		}
+	} else if app := findApp(path, config); app != "" {
+		server.App = app
+	}
+
+	for _, pkg := range []string{"gunicorn", "uvicorn"} {
+		if (pkg == "gunicorn" || server.ASGI) && !containsString(requirements, pkg) {
+			server.Packages = append(server.Packages, pkg)
+		}
+	}
+	project.AppServer = server
+}
+
+// findApp returns the module:variable of the application created with the
+// framework's factory in the first of its files that has one. Application
+// factory functions are called by gunicorn, as module:create_app().
+func findApp(path string, config *AppServerConfig) string {
+	if config.Factory == "" {
+		return ""
+	}
+	appRe := regexp.MustCompile(`(?m)^(\w+)\s*(?::\s*[\w.]+\s*)?=\s*(?:[\w.]+\.)?` + regexp.QuoteMeta(config.Factory) + `\(`)
+	factoryRe := regexp.MustCompile(`(?m)^def\s+(create_app|make_app)\s*\(`)
+	for _, file := range config.Files {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		module := strings.ReplaceAll(strings.TrimSuffix(strings.TrimSuffix(file, ".py"), "/__init__"), "/", ".")
+		if match := appRe.FindSubmatch(data); match != nil {
+			return module + ":" + string(match[1])
+		}
+		if match := factoryRe.FindSubmatch(data); match != nil {
+			return module + ":" + string(match[1]) + "()"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+var svelteConfigFiles = []string{"svelte.config.js", "svelte.config.mjs", "svelte.config.ts"}
+
+// detectAdapter inspects how SSR meta-frameworks are built and sets the
+// server entry and output directory. Some adapters produce a static site, in
+// which case the project is switched to static mode.
+func detectAdapter(path string, project *ProjectType) {
+	switch project.Framework {
+	case "nuxt":
+		if strings.Contains(packageScript(path, "build"), "nuxt generate") {
+			project.StaticSite = true
+			project.OutputDir = ".output/public"
+			return
+		}
+		project.OutputDir = ".output"
+		project.EntryPoint = ".output/server/index.mjs"
+
+	case "sveltekit":
+		config := configValue(path, svelteConfigFiles, `(@sveltejs/adapter-[\w-]+)`)
+		switch config {
+		case "@sveltejs/adapter-static":
+			project.StaticSite = true
+			project.OutputDir = configValue(path, svelteConfigFiles, `pages\s*:\s*["']([^"']+)["']`)
+			if project.OutputDir == "" {
+				project.OutputDir = "build"
+			}
+		case "@sveltejs/adapter-node":
+			project.OutputDir = configValue(path, svelteConfigFiles, `out\s*:\s*["']([^"']+)["']`)
+			if project.OutputDir == "" {
+				project.OutputDir = "build"
+			}
+			project.EntryPoint = project.OutputDir + "/index.js"
+		}
+
+	case "remix":
+		// Vite based Remix apps split the build into client and server folders
+		project.OutputDir = "build"
+		project.EntryPoint = "build/index.js"
+		if configValue(path, viteConfigFiles, `(@remix-run/dev)`) != "" {
+			project.EntryPoint = "build/server/index.js"
+		}
+
+	case "astro":
+		if packageDependency(path, "@astrojs/node") {
+			project.StaticSite = false
+			project.OutputDir = configValue(path, astroConfigFiles, `outDir\s*:\s*["']\.?/?([^"']+)["']`)
+			if project.OutputDir == "" {
+				project.OutputDir = "dist"
+			}
+			project.EntryPoint = project.OutputDir + "/server/entry.mjs"
+		}
+	}
+}
+
+// packageScript returns a script from package.json
+func packageScript(path, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(path, "package.json"))
+	if err != nil {
+		return ""
+	}
+
+	var packageJSON struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return ""
+	}
+	return packageJSON.Scripts[name]
+}
+
+// packageDependency reports whether package.json depends on the package
+func packageDependency(path, name string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(path, "package.json"))
+	if err != nil {
+		return false
+	}
+
+	var packageJSON struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return false
+	}
+	if _, ok := packageJSON.Dependencies[name]; ok {
+		return true
+	}
+	_, ok := packageJSON.DevDependencies[name]
+	return ok
+}
@@ -0,0 +1,147 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	viteConfigFiles  = []string{"vite.config.ts", "vite.config.js", "vite.config.mts", "vite.config.mjs", "vite.config.cjs"}
+	astroConfigFiles = []string{"astro.config.mjs", "astro.config.ts", "astro.config.js", "astro.config.mts"}
+	hugoConfigFiles  = []string{"hugo.toml", "hugo.yaml", "hugo.yml", "hugo.json", "config.toml", "config.yaml"}
+)
+
+func detectHugoFramework(path string, project *ProjectType, config *LanguageConfig) error {
+	if version := detectHugoVersion(path); version != "" {
+		project.Version = version
+	}
+
+	// Every Hugo site is a static site, there is nothing else to detect
+	if framework, ok := config.Frameworks["hugo"]; ok {
+		ApplyFramework(path, project, "hugo", framework)
+	}
+
+	return nil
+}
+
+// detectOutputDir works out where the framework's build writes the static
+// files, falling back to the catalog default.
+func detectOutputDir(path, framework, defaultDir string) string {
+	var dir string
+	switch framework {
+	case "angular":
+		dir = angularOutputPath(path)
+	case "hugo":
+		dir = configValue(path, hugoConfigFiles, `(?m)^\s*["']?publishDir["']?\s*[=:]\s*["']([^"']+)["']`)
+	case "astro":
+		dir = configValue(path, astroConfigFiles, `outDir\s*:\s*["']([^"']+)["']`)
+	case "vue":
+		dir = configValue(path, []string{"vue.config.js", "vue.config.ts"}, `outputDir\s*:\s*["']([^"']+)["']`)
+	}
+
+	// Most frameworks build through Vite nowadays, which writes to dist
+	// unless the config says otherwise
+	if dir == "" {
+		dir = configValue(path, viteConfigFiles, `outDir\s*:\s*["']([^"']+)["']`)
+		if dir == "" && firstExisting(path, viteConfigFiles) != "" {
+			dir = "dist"
+		}
+	}
+
+	if dir == "" {
+		dir = defaultDir
+	}
+	if dir == "" {
+		dir = "dist"
+	}
+
+	dir = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(dir)), "./")
+	return strings.TrimSuffix(dir, "/")
+}
+
+// configValue returns the first regexp submatch found in the given config files
+func configValue(path string, files []string, pattern string) string {
+	re := regexp.MustCompile(pattern)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		if matches := re.FindStringSubmatch(string(data)); len(matches) > 1 {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// angularOutputPath reads the browser output directory from angular.json
+func angularOutputPath(path string) string {
+	data, err := ioutil.ReadFile(filepath.Join(path, "angular.json"))
+	if err != nil {
+		return ""
+	}
+
+	var workspace struct {
+		DefaultProject string `json:"defaultProject"`
+		Projects       map[string]struct {
+			ProjectType string `json:"projectType"`
+			Architect   struct {
+				Build struct {
+					Builder string `json:"builder"`
+					Options struct {
+						OutputPath json.RawMessage `json:"outputPath"`
+					} `json:"options"`
+				} `json:"build"`
+			} `json:"architect"`
+		} `json:"projects"`
+	}
+	if err := json.Unmarshal(data, &workspace); err != nil {
+		return ""
+	}
+
+	name := workspace.DefaultProject
+	if _, ok := workspace.Projects[name]; !ok {
+		name = ""
+		for projectName, project := range workspace.Projects {
+			if project.ProjectType == "application" && (name == "" || projectName < name) {
+				name = projectName
+			}
+		}
+	}
+	project, ok := workspace.Projects[name]
+	if !ok {
+		return ""
+	}
+
+	build := project.Architect.Build
+	// The application builder (Angular 17+) writes the browser bundle to a subfolder
+	browser := ""
+	if strings.HasSuffix(build.Builder, ":application") {
+		browser = "browser"
+	}
+
+	var outputPath string
+	if err := json.Unmarshal(build.Options.OutputPath, &outputPath); err != nil {
+		var output struct {
+			Base    string  `json:"base"`
+			Browser *string `json:"browser"`
+		}
+		if err := json.Unmarshal(build.Options.OutputPath, &output); err != nil {
+			return ""
+		}
+		outputPath = output.Base
+		if output.Browser != nil {
+			browser = *output.Browser
+		}
+	}
+	if outputPath == "" {
+		outputPath = "dist/" + name
+	}
+	if browser != "" {
+		return outputPath + "/" + browser
+	}
+	return outputPath
+}
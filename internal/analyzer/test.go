@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TestConfig describes how the test stage runs a language's tests
+type TestConfig struct {
+	// Runners are tried in order, the first one the project is set up for
+	// runs the tests
+	Runners []TestRunner `yaml:"runners"`
+	// Requirements are files listing test dependencies, installed along
+	// with the runner's missing packages
+	Requirements []string `yaml:"requirements,omitempty"`
+	// Install is the command the requirement files, each after -r, and
+	// the packages are passed to
+	Install string `yaml:"install,omitempty"`
+}
+
+// TestRunner is a test command and what shows a project uses it
+type TestRunner struct {
+	Command string `yaml:"command"`
+	// Script is the package.json script that runs the tests
+	Script string `yaml:"script,omitempty"`
+	// Files are the runner's configuration files. file:text only matches
+	// files containing text, and a **/ prefix searches subdirectories.
+	Files []string `yaml:"files,omitempty"`
+	// Packages are installed when the project's requirements lack them
+	Packages []string `yaml:"packages,omitempty"`
+}
+
+// npmTestPlaceholder is the test script npm init writes
+const npmTestPlaceholder = "no test specified"
+
+// testSkippedDirs hold dependencies, not the project's tests
+var testSkippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "venv": true, ".venv": true,
+}
+
+// DetectTestCommand sets the command the test stage runs and what it
+// installs on top of the dev dependencies. It stays empty when none of the
+// language's test runners is set up.
+func DetectTestCommand(path string, project *ProjectType) {
+	project.TestCommand, project.TestInstall = "", ""
+	language, err := FindLanguageConfig(project.Language)
+	if err != nil {
+		return
+	}
+
+	var runner *TestRunner
+	for i := range language.Test.Runners {
+		if usesTestRunner(path, language.Test.Runners[i]) {
+			runner = &language.Test.Runners[i]
+			break
+		}
+	}
+	if runner == nil {
+		return
+	}
+	project.TestCommand = runner.Command
+
+	// Requirement files hold the test dependencies, the runner may be
+	// missing from them
+	var args []string
+	var listed []string
+	for _, file := range append([]string{"requirements.txt"}, language.Test.Requirements...) {
+		data, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		if file != "requirements.txt" {
+			args = append(args, "-r", file)
+		}
+		for _, match := range requirementNameRe.FindAllStringSubmatch(string(data), -1) {
+			listed = append(listed, strings.ToLower(match[1]))
+		}
+	}
+	for _, pkg := range runner.Packages {
+		if !containsString(listed, pkg) {
+			args = append(args, pkg)
+		}
+	}
+	if language.Test.Install != "" && len(args) > 0 {
+		project.TestInstall = language.Test.Install + " " + strings.Join(args, " ")
+	}
+}
+
+// usesTestRunner reports whether the project is set up for the runner
+func usesTestRunner(path string, runner TestRunner) bool {
+	if runner.Script != "" {
+		data, err := ioutil.ReadFile(filepath.Join(path, "package.json"))
+		if err != nil {
+			return false
+		}
+		var manifest struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(data, &manifest) != nil {
+			return false
+		}
+		script, ok := manifest.Scripts[runner.Script]
+		return ok && !strings.Contains(script, npmTestPlaceholder)
+	}
+
+	for _, file := range runner.Files {
+		name, text, contains := strings.Cut(file, ":")
+		if pattern, ok := strings.CutPrefix(name, "**/"); ok {
+			if findFile(path, pattern) {
+				return true
+			}
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err == nil && (!contains || strings.Contains(string(data), text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// findFile reports whether a file whose name matches pattern is anywhere
+// in the project
+func findFile(path, pattern string) bool {
+	found := false
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if file != path && testSkippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matched, _ := filepath.Match(pattern, info.Name()); matched {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
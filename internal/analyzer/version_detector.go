@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -153,6 +154,180 @@ func detectJavaVersion(path string) (string, error) {
 	return "17", nil
 }
 
+func detectDotnetVersion(path string) (string, error) {
+	// The highest TargetFramework across the projects decides the SDK and runtime
+	projects, err := findDotnetProjects(path)
+	if err != nil {
+		return "", err
+	}
+
+	re := regexp.MustCompile(`<TargetFrameworks?>([^<]+)</TargetFrameworks?>`)
+	tfmRe := regexp.MustCompile(`^net(?:coreapp)?(\d+)\.(\d+)`)
+	best := ""
+	bestMajor, bestMinor := -1, -1
+	for _, proj := range projects {
+		data, err := ioutil.ReadFile(filepath.Join(path, proj))
+		if err != nil {
+			continue
+		}
+		for _, match := range re.FindAllStringSubmatch(string(data), -1) {
+			for _, tfm := range strings.Split(match[1], ";") {
+				parts := tfmRe.FindStringSubmatch(strings.TrimSpace(tfm))
+				if len(parts) < 3 {
+					continue
+				}
+				major, _ := strconv.Atoi(parts[1])
+				minor, _ := strconv.Atoi(parts[2])
+				if major > bestMajor || (major == bestMajor && minor > bestMinor) {
+					bestMajor, bestMinor = major, minor
+					best = fmt.Sprintf("%d.%d", major, minor)
+				}
+			}
+		}
+	}
+	if best != "" {
+		return best, nil
+	}
+
+	// Fall back to the SDK pinned in global.json
+	if data, err := ioutil.ReadFile(filepath.Join(path, "global.json")); err == nil {
+		var global struct {
+			SDK struct {
+				Version string `json:"version"`
+			} `json:"sdk"`
+		}
+		if err := json.Unmarshal(data, &global); err == nil && global.SDK.Version != "" {
+			if version := parseVersionConstraint(global.SDK.Version); version != "" {
+				return version, nil
+			}
+		}
+	}
+
+	// Default to latest LTS
+	return "8.0", nil
+}
+
+// detectElixirVersion returns an official elixir image tag prefix such as
+// "1.16.0-otp-26" or "1.16", preferring the pins in .tool-versions.
+func detectElixirVersion(path string) (string, error) {
+	if data, err := ioutil.ReadFile(filepath.Join(path, ".tool-versions")); err == nil {
+		var elixir, otp string
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "elixir":
+				elixir = fields[1]
+			case "erlang":
+				otp = strings.Split(fields[1], ".")[0]
+			}
+		}
+		if elixir != "" {
+			// asdf pins may already carry the OTP suffix (1.16.0-otp-26)
+			if idx := strings.Index(elixir, "-otp-"); idx != -1 {
+				if otp == "" {
+					otp = elixir[idx+len("-otp-"):]
+				}
+				elixir = elixir[:idx]
+			}
+			if otp != "" {
+				return fmt.Sprintf("%s-otp-%s", elixir, otp), nil
+			}
+			return elixir, nil
+		}
+	}
+
+	// Check the elixir requirement in mix.exs
+	if data, err := ioutil.ReadFile(filepath.Join(path, "mix.exs")); err == nil {
+		re := regexp.MustCompile(`elixir:\s*"[~>=\s]*(\d+\.\d+)`)
+		if matches := re.FindStringSubmatch(string(data)); len(matches) > 1 {
+			return matches[1], nil
+		}
+	}
+
+	// Default to latest stable
+	return "1.16", nil
+}
+
+// DefaultDenoVersion is the Deno image of projects that pin no version
+const DefaultDenoVersion = "2.1.4"
+
+func detectDenoVersion(path string) (string, error) {
+	// Check version manager pins
+	if data, err := ioutil.ReadFile(filepath.Join(path, ".dvmrc")); err == nil {
+		if version := strings.TrimPrefix(strings.TrimSpace(string(data)), "v"); version != "" {
+			return version, nil
+		}
+	}
+	if version := toolVersion(path, "deno"); version != "" {
+		return version, nil
+	}
+
+	// Default to a pinned release rather than the floating latest tag
+	return DefaultDenoVersion, nil
+}
+
+func detectBunVersion(path string) (string, error) {
+	if data, err := ioutil.ReadFile(filepath.Join(path, ".bun-version")); err == nil {
+		if version := strings.TrimPrefix(strings.TrimSpace(string(data)), "v"); version != "" {
+			return version, nil
+		}
+	}
+	if version := toolVersion(path, "bun"); version != "" {
+		return version, nil
+	}
+
+	// Check package.json engines and packageManager
+	if data, err := ioutil.ReadFile(filepath.Join(path, "package.json")); err == nil {
+		var pkg struct {
+			Engines struct {
+				Bun string `json:"bun"`
+			} `json:"engines"`
+			PackageManager string `json:"packageManager"`
+		}
+		if err := json.Unmarshal(data, &pkg); err == nil {
+			if pkg.Engines.Bun != "" {
+				if version := parseVersionConstraint(pkg.Engines.Bun); version != "" {
+					return version, nil
+				}
+			}
+			if strings.HasPrefix(pkg.PackageManager, "bun@") {
+				if version := parseVersionConstraint(strings.TrimPrefix(pkg.PackageManager, "bun@")); version != "" {
+					return version, nil
+				}
+			}
+		}
+	}
+
+	// Default to latest stable major
+	return "1", nil
+}
+
+// toolVersion returns the version pinned for tool in an asdf .tool-versions file
+func toolVersion(path, tool string) string {
+	data, err := ioutil.ReadFile(filepath.Join(path, ".tool-versions"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == tool {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// detectHugoVersion reads the minimum Hugo version from the site config
+func detectHugoVersion(path string) string {
+	if version := configValue(path, hugoConfigFiles, `min\s*[=:]\s*["']v?(\d+\.\d+\.\d+)`); version != "" {
+		return version
+	}
+	return toolVersion(path, "hugo")
+}
+
 func parseVersionConstraint(constraint string) string {
 	// Remove version operators and spaces
 	constraint = strings.TrimSpace(constraint)
@@ -212,6 +387,30 @@ func UpdateBaseImage(project *ProjectType) error {
 		if err == nil && version != "" {
 			project.BaseImage = fmt.Sprintf("php:%s-fpm", version)
 		}
+
+	case ".NET":
+		version, err = detectDotnetVersion(".")
+		if err == nil && version != "" {
+			project.BaseImage = fmt.Sprintf("mcr.microsoft.com/dotnet/sdk:%s", version)
+		}
+
+	case "Elixir":
+		version, err = detectElixirVersion(".")
+		if err == nil && version != "" {
+			project.BaseImage = fmt.Sprintf("elixir:%s-slim", version)
+		}
+
+	case "Deno":
+		version, err = detectDenoVersion(".")
+		if err == nil && version != "" {
+			project.BaseImage = fmt.Sprintf("denoland/deno:%s", version)
+		}
+
+	case "Bun":
+		version, err = detectBunVersion(".")
+		if err == nil && version != "" {
+			project.BaseImage = fmt.Sprintf("oven/bun:%s", version)
+		}
 	}
 
 	return nil
@@ -0,0 +1,200 @@
+package analyzer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Process is an additional long running process of the application, such as
+// a background worker or scheduler. It runs from the same image as the app.
+type Process struct {
+	Name    string
+	Command string
+	OneOff  bool
+}
+
+// pythonDependencyFiles are the files that declare Python dependencies
+var pythonDependencyFiles = []string{"requirements.txt", "requirements/base.txt", "requirements/prod.txt", "pyproject.toml", "Pipfile", "setup.py"}
+
+var (
+	celeryAppRe      = regexp.MustCompile(`(?m)^\s*(\w+)\s*=\s*Celery\(`)
+	celeryBeatRe     = regexp.MustCompile(`beat_schedule|CELERY_BEAT_SCHEDULE|CELERYBEAT_SCHEDULE|add_periodic_task|@periodic_task`)
+	dramatiqActorRe  = regexp.MustCompile(`@(dramatiq\.)?actor\b`)
+	rqQueueRe        = regexp.MustCompile(`Queue\(\s*["']([\w-]+)["']`)
+	amqpBrokerRe     = regexp.MustCompile(`amqp://|pyamqp://|RabbitmqBroker`)
+	pythonSkippedDir = map[string]bool{"venv": true, "env": true, "node_modules": true, "__pycache__": true, "site-packages": true, "migrations": true}
+)
+
+// detectPythonWorkers looks for Celery, RQ and Dramatiq apps and records the
+// worker processes they need together with the broker service.
+func detectPythonWorkers(path string, project *ProjectType) {
+	deps := ""
+	for _, file := range pythonDependencyFiles {
+		if data, err := ioutil.ReadFile(filepath.Join(path, file)); err == nil {
+			deps += strings.ToLower(string(data)) + "\n"
+		}
+	}
+	hasDep := func(name string) bool {
+		return regexp.MustCompile(`(?m)(^|["'\s])` + regexp.QuoteMeta(name) + `\b`).MatchString(deps)
+	}
+
+	if !hasDep("celery") && !hasDep("rq") && !hasDep("dramatiq") {
+		return
+	}
+
+	sources := pythonSources(path)
+
+	if hasDep("celery") {
+		for _, file := range sources.names {
+			content := sources.content[file]
+			matches := celeryAppRe.FindStringSubmatch(content)
+			if len(matches) < 2 {
+				continue
+			}
+
+			app := pythonModule(file)
+			if matches[1] != "app" && matches[1] != "celery" {
+				app += ":" + matches[1]
+			}
+			project.Broker = "redis"
+			if amqpBrokerRe.MatchString(content) {
+				project.Broker = "rabbitmq"
+			}
+			project.AdditionalServices = appendUnique(project.AdditionalServices, project.Broker)
+
+			project.Processes = append(project.Processes, Process{
+				Name:    "worker",
+				Command: "celery -A " + app + " worker --loglevel=info",
+			})
+
+			// Periodic tasks need a single beat scheduler next to the workers
+			beat := hasDep("django-celery-beat") || hasDep("django_celery_beat")
+			for _, source := range sources.content {
+				if celeryBeatRe.MatchString(source) {
+					beat = true
+					break
+				}
+			}
+			if beat {
+				command := "celery -A " + app + " beat --loglevel=info"
+				if hasDep("django-celery-beat") || hasDep("django_celery_beat") {
+					command += " --scheduler django_celery_beat.schedulers:DatabaseScheduler"
+				}
+				project.Processes = append(project.Processes, Process{Name: "beat", Command: command})
+			}
+			break
+		}
+	}
+
+	if hasDep("rq") {
+		var queues []string
+		seen := make(map[string]bool)
+		for _, file := range sources.names {
+			for _, match := range rqQueueRe.FindAllStringSubmatch(sources.content[file], -1) {
+				if !seen[match[1]] {
+					seen[match[1]] = true
+					queues = append(queues, match[1])
+				}
+			}
+		}
+		command := "rq worker --url redis://redis:6379/0"
+		if len(queues) > 0 {
+			command += " " + strings.Join(queues, " ")
+		}
+		project.Processes = append(project.Processes, Process{Name: "rq-worker", Command: command})
+		project.AdditionalServices = appendUnique(project.AdditionalServices, "redis")
+		if hasDep("rq-scheduler") {
+			project.Processes = append(project.Processes, Process{
+				Name:    "rq-scheduler",
+				Command: "rqscheduler --url redis://redis:6379/0",
+			})
+		}
+	}
+
+	if hasDep("dramatiq") {
+		var modules []string
+		usesRedis := false
+		for _, file := range sources.names {
+			content := sources.content[file]
+			if strings.Contains(content, "RedisBroker") {
+				usesRedis = true
+			}
+			if strings.Contains(content, "dramatiq") && dramatiqActorRe.MatchString(content) {
+				modules = append(modules, pythonModule(file))
+			}
+		}
+		if len(modules) > 0 {
+			project.Processes = append(project.Processes, Process{
+				Name:    "dramatiq",
+				Command: "dramatiq " + strings.Join(modules, " "),
+			})
+			// Dramatiq defaults to RabbitMQ unless a Redis broker is configured
+			broker := "rabbitmq"
+			if usesRedis {
+				broker = "redis"
+			}
+			if project.Broker == "" {
+				project.Broker = broker
+			}
+			project.AdditionalServices = appendUnique(project.AdditionalServices, broker)
+		}
+	}
+}
+
+// pythonSourceFiles holds the Python files of a project in a stable order
+type pythonSourceFiles struct {
+	names   []string
+	content map[string]string
+}
+
+// pythonSources reads the project's Python files, skipping virtualenvs and
+// other directories that never contain application code
+func pythonSources(path string) pythonSourceFiles {
+	sources := pythonSourceFiles{content: make(map[string]string)}
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if rel != "." && (strings.HasPrefix(info.Name(), ".") || pythonSkippedDir[info.Name()] || strings.Count(rel, string(filepath.Separator)) >= 4) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".py") {
+			if data, err := ioutil.ReadFile(file); err == nil {
+				rel = filepath.ToSlash(rel)
+				sources.names = append(sources.names, rel)
+				sources.content[rel] = string(data)
+			}
+		}
+		return nil
+	})
+	sort.Strings(sources.names)
+	return sources
+}
+
+// pythonModule converts a file path such as "proj/celery.py" to "proj.celery"
+func pythonModule(file string) string {
+	module := strings.TrimSuffix(filepath.ToSlash(file), ".py")
+	module = strings.TrimSuffix(module, "/__init__")
+	return strings.ReplaceAll(module, "/", ".")
+}
+
+// appendUnique appends value unless the slice already contains it
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,302 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace describes the monorepo a project lives in: a JavaScript workspace
+// or a set of local Go modules. Paths are relative to the workspace root
+// unless noted otherwise and use forward slashes.
+type Workspace struct {
+	// Root is the workspace root relative to the project directory
+	Root    string
+	Manager string
+	// Tool is the task runner orchestrating builds (turbo, nx) if any
+	Tool string
+	// Package is the directory of the project, empty at the root itself
+	Package string
+	Name    string
+	// Packages maps every workspace package name to its directory
+	Packages map[string]WorkspacePackage
+	// Members are the directories the project needs, dependencies first
+	Members []string
+	// Excluded are workspace members left out of the build context
+	Excluded []string
+	// RootFiles are root-level files needed to install and build
+	RootFiles []string
+}
+
+// WorkspacePackage is a package that is part of a workspace
+type WorkspacePackage struct {
+	Name         string
+	Dir          string
+	Dependencies []string
+	Scripts      map[string]string
+}
+
+// workspaceRootFiles are copied from the workspace root when present
+var workspaceRootFiles = []string{
+	"package.json", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+	"pnpm-workspace.yaml", ".npmrc", ".yarnrc", ".yarnrc.yml", ".yarn",
+	"turbo.json", "nx.json", "lerna.json", "tsconfig.json", "tsconfig.base.json",
+}
+
+// nodeManifest is the part of package.json relevant to workspaces
+type nodeManifest struct {
+	Name                 string            `json:"name"`
+	Workspaces           json.RawMessage   `json:"workspaces"`
+	Scripts              map[string]string `json:"scripts"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PackageManager       string            `json:"packageManager"`
+}
+
+// FindWorkspace walks up from path looking for an npm, yarn or pnpm
+// workspace root. It returns nil if the project is not part of a workspace.
+func FindWorkspace(path string) (*Workspace, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		patterns, ok := workspacePatterns(dir)
+		if ok {
+			return loadWorkspace(abs, dir, patterns)
+		}
+
+		// Do not leave the repository
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil, nil
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+// workspacePatterns returns the workspace globs declared in dir, if any
+func workspacePatterns(dir string) ([]string, bool) {
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "pnpm-workspace.yaml")); err == nil {
+		var config struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &config); err == nil {
+			return config.Packages, true
+		}
+	}
+
+	manifest, err := readNodeManifest(dir)
+	if err != nil || len(manifest.Workspaces) == 0 {
+		return nil, false
+	}
+
+	// Workspaces are either a list or {"packages": [...]} (yarn classic)
+	var patterns []string
+	if err := json.Unmarshal(manifest.Workspaces, &patterns); err != nil {
+		var config struct {
+			Packages []string `json:"packages"`
+		}
+		if err := json.Unmarshal(manifest.Workspaces, &config); err != nil {
+			return nil, false
+		}
+		patterns = config.Packages
+	}
+	return patterns, true
+}
+
+func loadWorkspace(projectDir, rootDir string, patterns []string) (*Workspace, error) {
+	workspace := &Workspace{
+		Manager:  workspaceManager(rootDir),
+		Packages: make(map[string]WorkspacePackage),
+	}
+
+	root, err := filepath.Rel(projectDir, rootDir)
+	if err != nil {
+		return nil, err
+	}
+	workspace.Root = filepath.ToSlash(root)
+
+	if pkg, err := filepath.Rel(rootDir, projectDir); err == nil && pkg != "." {
+		workspace.Package = filepath.ToSlash(pkg)
+	}
+
+	switch {
+	case fileExists(filepath.Join(rootDir, "turbo.json")):
+		workspace.Tool = "turbo"
+	case fileExists(filepath.Join(rootDir, "nx.json")):
+		workspace.Tool = "nx"
+	}
+
+	for _, name := range workspaceRootFiles {
+		if fileExists(filepath.Join(rootDir, name)) {
+			workspace.RootFiles = append(workspace.RootFiles, name)
+		}
+	}
+
+	for _, dir := range expandWorkspacePatterns(rootDir, patterns) {
+		manifest, err := readNodeManifest(filepath.Join(rootDir, dir))
+		if err != nil || manifest.Name == "" {
+			continue
+		}
+		pkg := WorkspacePackage{Name: manifest.Name, Dir: dir, Scripts: manifest.Scripts}
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.PeerDependencies, manifest.OptionalDependencies} {
+			for dep := range deps {
+				pkg.Dependencies = append(pkg.Dependencies, dep)
+			}
+		}
+		sort.Strings(pkg.Dependencies)
+		workspace.Packages[manifest.Name] = pkg
+		if dir == workspace.Package {
+			workspace.Name = manifest.Name
+		}
+	}
+
+	if workspace.Name != "" {
+		workspace.Members = workspace.Prune(workspace.Name)
+	}
+	return workspace, nil
+}
+
+// Prune returns the directories of a package and every workspace package it
+// depends on, directly or transitively, in dependency order. Only these need
+// to be in the build context, like `turbo prune --docker` would produce.
+func (w *Workspace) Prune(name string) []string {
+	var members []string
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		pkg, ok := w.Packages[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dep := range pkg.Dependencies {
+			visit(dep)
+		}
+		members = append(members, pkg.Dir)
+	}
+	visit(name)
+
+	return members
+}
+
+// Applications returns the workspace packages that can be run or built,
+// sorted by directory
+func (w *Workspace) Applications() []WorkspacePackage {
+	var apps []WorkspacePackage
+	for _, pkg := range w.Packages {
+		if pkg.Scripts["start"] != "" || pkg.Scripts["build"] != "" {
+			apps = append(apps, pkg)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Dir < apps[j].Dir })
+	return apps
+}
+
+// Member returns the workspace package in a member directory
+func (w *Workspace) Member(dir string) (WorkspacePackage, bool) {
+	for _, pkg := range w.Packages {
+		if pkg.Dir == dir {
+			return pkg, true
+		}
+	}
+	return WorkspacePackage{}, false
+}
+
+// expandWorkspacePatterns resolves workspace globs to package directories
+func expandWorkspacePatterns(rootDir string, patterns []string) []string {
+	included := make(map[string]bool)
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "./")
+
+		var dirs []string
+		if strings.HasSuffix(pattern, "/**") {
+			// Recursive patterns match every directory with a package.json
+			base := filepath.Join(rootDir, strings.TrimSuffix(pattern, "/**"))
+			filepath.Walk(base, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				if info.IsDir() && info.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				if !info.IsDir() && info.Name() == "package.json" {
+					dirs = append(dirs, filepath.Dir(file))
+				}
+				return nil
+			})
+		} else {
+			dirs, _ = filepath.Glob(filepath.Join(rootDir, pattern))
+		}
+
+		for _, dir := range dirs {
+			rel, err := filepath.Rel(rootDir, dir)
+			if err != nil || !fileExists(filepath.Join(dir, "package.json")) {
+				continue
+			}
+			included[filepath.ToSlash(rel)] = !exclude
+		}
+	}
+
+	var dirs []string
+	for dir, include := range included {
+		if include {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// workspaceManager detects the package manager from lockfiles and packageManager
+func workspaceManager(rootDir string) string {
+	switch {
+	case fileExists(filepath.Join(rootDir, "pnpm-lock.yaml")), fileExists(filepath.Join(rootDir, "pnpm-workspace.yaml")):
+		return "pnpm"
+	case fileExists(filepath.Join(rootDir, ".yarnrc.yml")):
+		return "yarn-berry"
+	case fileExists(filepath.Join(rootDir, "yarn.lock")):
+		return "yarn"
+	}
+
+	if manifest, err := readNodeManifest(rootDir); err == nil {
+		switch {
+		case strings.HasPrefix(manifest.PackageManager, "pnpm@"):
+			return "pnpm"
+		case strings.HasPrefix(manifest.PackageManager, "yarn@1"):
+			return "yarn"
+		case strings.HasPrefix(manifest.PackageManager, "yarn@"):
+			return "yarn-berry"
+		}
+	}
+	return "npm"
+}
+
+func readNodeManifest(dir string) (*nodeManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+	var manifest nodeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,296 @@
+// Package dockerfile is a typed model of Dockerfiles. Generators build it
+// programmatically or from templates, existing files are parsed into it, and
+// Format renders it back in a consistent layout.
+package dockerfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dockerfile is a parsed or generated Dockerfile
+type Dockerfile struct {
+	// Directives are parser directives such as syntax=docker/dockerfile:1
+	Directives []Directive
+	// Args are the global ARG instructions before the first FROM
+	Args   []*Instruction
+	Stages []*Stage
+	// Comments after the last instruction
+	Trailing []string
+}
+
+// Directive is a parser directive at the very top of the file
+type Directive struct {
+	Name  string
+	Value string
+}
+
+// Stage is a FROM instruction and the instructions of its build stage
+type Stage struct {
+	Comments []string
+	// Flags of the FROM instruction, e.g. --platform=$BUILDPLATFORM
+	Flags        []string
+	Image        string
+	Name         string
+	Instructions []*Instruction
+	// Spaced records a blank line before the stage in a parsed file
+	Spaced bool
+}
+
+// Instruction is a single Dockerfile instruction
+type Instruction struct {
+	Comments []string
+	Keyword  string
+	// Flags are the leading --options, e.g. --from=builder or --mount=...
+	Flags []string
+	// Args are the arguments as written, including line continuations
+	Args     string
+	Heredocs []Heredoc
+	// Spaced records a blank line before the instruction in a parsed file
+	Spaced bool
+}
+
+// Heredoc is an inline document following an instruction, e.g. RUN <<EOF
+type Heredoc struct {
+	Name string
+	// Chomp strips leading tabs from the body (<<-EOF)
+	Chomp bool
+	Body  string
+}
+
+// New returns an empty Dockerfile
+func New() *Dockerfile {
+	return &Dockerfile{}
+}
+
+// SetDirective adds or replaces a parser directive
+func (d *Dockerfile) SetDirective(name, value string) {
+	for i := range d.Directives {
+		if d.Directives[i].Name == name {
+			d.Directives[i].Value = value
+			return
+		}
+	}
+	d.Directives = append(d.Directives, Directive{Name: name, Value: value})
+}
+
+// Directive returns the value of a parser directive
+func (d *Dockerfile) Directive(name string) string {
+	for _, directive := range d.Directives {
+		if directive.Name == name {
+			return directive.Value
+		}
+	}
+	return ""
+}
+
+// Arg adds a global ARG, available to FROM lines
+func (d *Dockerfile) Arg(arg string) *Instruction {
+	instruction := &Instruction{Keyword: "ARG", Args: arg}
+	d.Args = append(d.Args, instruction)
+	return instruction
+}
+
+// From starts a new build stage. The name may be empty.
+func (d *Dockerfile) From(image, name string, flags ...string) *Stage {
+	stage := &Stage{Image: image, Name: name, Flags: flags}
+	d.Stages = append(d.Stages, stage)
+	return stage
+}
+
+// Stage returns the stage with the given name, or nil
+func (d *Dockerfile) Stage(name string) *Stage {
+	for _, stage := range d.Stages {
+		if strings.EqualFold(stage.Name, name) {
+			return stage
+		}
+	}
+	return nil
+}
+
+// FinalStage returns the stage that ends up in the image, or nil
+func (d *Dockerfile) FinalStage() *Stage {
+	if len(d.Stages) == 0 {
+		return nil
+	}
+	return d.Stages[len(d.Stages)-1]
+}
+
+// Tidy drops the blank lines recorded while parsing, so the formatter only
+// separates stages and commented paragraphs. Rendered templates are full
+// of blank lines left behind by template actions.
+func (d *Dockerfile) Tidy() {
+	for _, instruction := range d.Args {
+		instruction.Spaced = false
+	}
+	for _, stage := range d.Stages {
+		stage.Spaced = false
+		for _, instruction := range stage.Instructions {
+			instruction.Spaced = false
+		}
+	}
+}
+
+// Validate reports problems that would make the build fail
+func (d *Dockerfile) Validate() error {
+	if len(d.Stages) == 0 {
+		return fmt.Errorf("no FROM instruction")
+	}
+
+	names := make(map[string]bool)
+	for i, stage := range d.Stages {
+		if stage.Image == "" {
+			return fmt.Errorf("stage %d has no base image", i+1)
+		}
+		if stage.Name != "" {
+			name := strings.ToLower(stage.Name)
+			if names[name] {
+				return fmt.Errorf("duplicate stage name %q", stage.Name)
+			}
+			names[name] = true
+		}
+
+		for _, instruction := range stage.Instructions {
+			// COPY --from must name an earlier stage, a stage index or an image
+			from := instruction.Flag("from")
+			if from == "" || names[strings.ToLower(from)] || strings.ContainsAny(from, ":/") {
+				continue
+			}
+			if index, err := strconv.Atoi(from); err == nil && index < i {
+				continue
+			}
+			return fmt.Errorf("%s --from=%s refers to an unknown stage", instruction.Keyword, from)
+		}
+	}
+	return nil
+}
+
+// Add appends an instruction to the stage
+func (s *Stage) Add(keyword, args string, flags ...string) *Instruction {
+	instruction := &Instruction{Keyword: strings.ToUpper(keyword), Args: args, Flags: flags}
+	s.Instructions = append(s.Instructions, instruction)
+	return instruction
+}
+
+// Insert adds an instruction before position index
+func (s *Stage) Insert(index int, instruction *Instruction) {
+	if index < 0 || index > len(s.Instructions) {
+		index = len(s.Instructions)
+	}
+	s.Instructions = append(s.Instructions, nil)
+	copy(s.Instructions[index+1:], s.Instructions[index:])
+	s.Instructions[index] = instruction
+}
+
+// Remove deletes an instruction from the stage
+func (s *Stage) Remove(instruction *Instruction) {
+	for i, existing := range s.Instructions {
+		if existing == instruction {
+			s.Instructions = append(s.Instructions[:i], s.Instructions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Index returns the position of the first instruction with keyword, or -1
+func (s *Stage) Index(keyword string) int {
+	for i, instruction := range s.Instructions {
+		if instruction.Keyword == strings.ToUpper(keyword) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find returns the instructions with the given keyword
+func (s *Stage) Find(keyword string) []*Instruction {
+	var found []*Instruction
+	for _, instruction := range s.Instructions {
+		if instruction.Keyword == strings.ToUpper(keyword) {
+			found = append(found, instruction)
+		}
+	}
+	return found
+}
+
+// Run appends a RUN instruction
+func (s *Stage) Run(command string, flags ...string) *Instruction {
+	return s.Add("RUN", command, flags...)
+}
+
+// Copy appends a COPY instruction
+func (s *Stage) Copy(src, dest string, flags ...string) *Instruction {
+	return s.Add("COPY", src+" "+dest, flags...)
+}
+
+// Workdir appends a WORKDIR instruction
+func (s *Stage) Workdir(dir string) *Instruction {
+	return s.Add("WORKDIR", dir)
+}
+
+// Env appends an ENV instruction
+func (s *Stage) Env(key, value string) *Instruction {
+	return s.Add("ENV", key+"="+value)
+}
+
+// Expose appends an EXPOSE instruction
+func (s *Stage) Expose(ports ...string) *Instruction {
+	return s.Add("EXPOSE", strings.Join(ports, " "))
+}
+
+// User appends a USER instruction
+func (s *Stage) User(user string) *Instruction {
+	return s.Add("USER", user)
+}
+
+// Cmd appends a CMD instruction in exec form
+func (s *Stage) Cmd(args ...string) *Instruction {
+	return s.Add("CMD", ExecForm(args...))
+}
+
+// Entrypoint appends an ENTRYPOINT instruction in exec form
+func (s *Stage) Entrypoint(args ...string) *Instruction {
+	return s.Add("ENTRYPOINT", ExecForm(args...))
+}
+
+// Comment attaches comment lines to the instruction
+func (i *Instruction) Comment(lines ...string) *Instruction {
+	i.Comments = append(i.Comments, lines...)
+	return i
+}
+
+// Flag returns the value of a --name=value flag
+func (i *Instruction) Flag(name string) string {
+	for _, flag := range i.Flags {
+		if value, ok := strings.CutPrefix(flag, "--"+name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// Fields returns the arguments split on whitespace, ignoring continuations
+func (i *Instruction) Fields() []string {
+	return strings.Fields(strings.ReplaceAll(i.Args, "\\\n", " "))
+}
+
+// ExecArgs returns the arguments of an instruction in exec form, such as
+// CMD ["npm", "start"], and false for the shell form
+func (i *Instruction) ExecArgs() ([]string, bool) {
+	var args []string
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(i.Args, "\\\n", " ")), &args); err != nil {
+		return nil, false
+	}
+	return args, true
+}
+
+// ExecForm renders arguments as a JSON array, e.g. ["npm", "start"]
+func ExecForm(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,94 @@
+package dockerfile
+
+import (
+	"strings"
+)
+
+// Format renders the Dockerfile. Stages are separated by a blank line, and
+// so are commented paragraphs and the blank lines kept from a parsed file.
+func Format(d *Dockerfile) string {
+	var b strings.Builder
+
+	for _, directive := range d.Directives {
+		b.WriteString("# " + directive.Name + "=" + directive.Value + "\n")
+	}
+	if len(d.Directives) > 0 {
+		b.WriteString("\n")
+	}
+
+	for i, instruction := range d.Args {
+		writeInstruction(&b, instruction, i > 0)
+	}
+	if len(d.Args) > 0 {
+		b.WriteString("\n")
+	}
+
+	for i, stage := range d.Stages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeComments(&b, stage.Comments)
+		from := append([]string{"FROM"}, stage.Flags...)
+		from = append(from, stage.Image)
+		if stage.Name != "" {
+			from = append(from, "AS", stage.Name)
+		}
+		b.WriteString(strings.Join(from, " ") + "\n")
+
+		for _, instruction := range stage.Instructions {
+			writeInstruction(&b, instruction, true)
+		}
+	}
+
+	if len(d.Trailing) > 0 {
+		b.WriteString("\n")
+		writeComments(&b, d.Trailing)
+	}
+
+	// A leading comment that reads like a parser directive would become one,
+	// an empty comment line before it keeps it a comment
+	output := b.String()
+	first, _, _ := strings.Cut(output, "\n")
+	if len(d.Directives) == 0 && directiveRe.MatchString(first) {
+		output = "#\n" + output
+	}
+	return output
+}
+
+// String renders the Dockerfile with Format
+func (d *Dockerfile) String() string {
+	return Format(d)
+}
+
+// String renders a single instruction without its comments
+func (i *Instruction) String() string {
+	parts := append([]string{i.Keyword}, i.Flags...)
+	if i.Args != "" {
+		parts = append(parts, i.Args)
+	}
+	return strings.Join(parts, " ")
+}
+
+func writeInstruction(b *strings.Builder, instruction *Instruction, separate bool) {
+	if separate && (instruction.Spaced || len(instruction.Comments) > 0) {
+		b.WriteString("\n")
+	}
+	writeComments(b, instruction.Comments)
+	b.WriteString(instruction.String() + "\n")
+	for _, heredoc := range instruction.Heredocs {
+		if heredoc.Body != "" {
+			b.WriteString(heredoc.Body + "\n")
+		}
+		b.WriteString(heredoc.Name + "\n")
+	}
+}
+
+func writeComments(b *strings.Builder, comments []string) {
+	for _, comment := range comments {
+		if comment == "" {
+			b.WriteString("#\n")
+			continue
+		}
+		b.WriteString("# " + comment + "\n")
+	}
+}
@@ -0,0 +1,168 @@
+package dockerfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	directiveRe = regexp.MustCompile(`^#\s*(syntax|escape|check)\s*=\s*(\S+)\s*$`)
+	heredocRe   = regexp.MustCompile(`<<(-?)(["']?)([A-Za-z_][A-Za-z0-9_]*)(["']?)`)
+)
+
+// ParseFile reads a Dockerfile from disk
+func ParseFile(path string) (*Dockerfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse reads a Dockerfile. Only the default escape character is supported.
+func Parse(r io.Reader) (*Dockerfile, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	d := New()
+	i := 0
+
+	// Parser directives must come before anything else, blank lines included
+	for ; i < len(lines); i++ {
+		matches := directiveRe.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if matches == nil {
+			break
+		}
+		d.SetDirective(strings.ToLower(matches[1]), matches[2])
+	}
+
+	var comments []string
+	spaced := false
+	for i < len(lines) {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			spaced = true
+			i++
+			continue
+		case strings.HasPrefix(line, "#"):
+			comments = append(comments, strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "))
+			i++
+			continue
+		}
+
+		// Join continuation lines, keeping their layout, until one ends
+		// without a backslash. Comment and blank lines inside an instruction
+		// are ignored by Docker and dropped here as well.
+		start := i + 1
+		raw := strings.TrimLeft(lines[i], " \t")
+		last := lines[i]
+		for strings.HasSuffix(strings.TrimRight(last, " \t"), "\\") && i+1 < len(lines) {
+			i++
+			if next := strings.TrimSpace(lines[i]); next == "" || strings.HasPrefix(next, "#") {
+				continue
+			}
+			raw = strings.TrimRight(raw, " \t") + "\n" + lines[i]
+			last = lines[i]
+		}
+		i++
+
+		instruction, err := parseInstruction(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		instruction.Comments = comments
+		instruction.Spaced = spaced
+		comments, spaced = nil, false
+
+		// Heredoc bodies follow the instruction, in the order of their markers
+		for _, marker := range heredocRe.FindAllStringSubmatch(raw, -1) {
+			heredoc := Heredoc{Name: marker[3], Chomp: marker[1] == "-"}
+			var body []string
+			for ; i < len(lines); i++ {
+				end := lines[i]
+				if heredoc.Chomp {
+					end = strings.TrimLeft(end, "\t")
+				}
+				if end == heredoc.Name {
+					i++
+					break
+				}
+				body = append(body, lines[i])
+			}
+			heredoc.Body = strings.Join(body, "\n")
+			instruction.Heredocs = append(instruction.Heredocs, heredoc)
+		}
+
+		if instruction.Keyword == "FROM" {
+			fields := instruction.Fields()
+			stage := &Stage{Comments: instruction.Comments, Flags: instruction.Flags, Spaced: instruction.Spaced}
+			if len(fields) > 0 {
+				stage.Image = fields[0]
+			}
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+				stage.Name = fields[2]
+			}
+			d.Stages = append(d.Stages, stage)
+			continue
+		}
+
+		if len(d.Stages) == 0 {
+			if instruction.Keyword != "ARG" {
+				return nil, fmt.Errorf("line %d: %s before the first FROM", start, instruction.Keyword)
+			}
+			d.Args = append(d.Args, instruction)
+			continue
+		}
+		stage := d.Stages[len(d.Stages)-1]
+		stage.Instructions = append(stage.Instructions, instruction)
+	}
+
+	d.Trailing = comments
+	return d, nil
+}
+
+// parseInstruction splits an instruction into keyword, flags and arguments
+func parseInstruction(raw string) (*Instruction, error) {
+	end := strings.IndexAny(raw, " \t\n")
+	if end == -1 {
+		end = len(raw)
+	}
+	keyword := strings.TrimSuffix(raw[:end], "\\")
+	if keyword == "" {
+		return nil, fmt.Errorf("empty instruction")
+	}
+
+	instruction := &Instruction{Keyword: strings.ToUpper(keyword)}
+	rest := raw[end:]
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		if strings.HasPrefix(rest, "\\\n") {
+			rest = rest[2:]
+			continue
+		}
+		if !strings.HasPrefix(rest, "--") {
+			break
+		}
+		flagEnd := strings.IndexAny(rest, " \t\n")
+		if flagEnd == -1 {
+			flagEnd = len(rest)
+		}
+		instruction.Flags = append(instruction.Flags, strings.TrimSuffix(rest[:flagEnd], "\\"))
+		rest = rest[flagEnd:]
+	}
+	instruction.Args = strings.TrimRight(rest, " \t")
+	return instruction, nil
+}
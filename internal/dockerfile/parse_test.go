@@ -0,0 +1,150 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+)
+
+// roundTrip parses a Dockerfile, formats it and checks formatting the
+// result again changes nothing
+func roundTrip(t *testing.T, input string) (*Dockerfile, string) {
+	t.Helper()
+	d, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	output := Format(d)
+
+	again, err := Parse(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("Parse of formatted output: %v\n%s", err, output)
+	}
+	if second := Format(again); second != output {
+		t.Errorf("formatting is not stable:\n%s\n---\n%s", output, second)
+	}
+	return d, output
+}
+
+func TestParseContinuationWithComments(t *testing.T) {
+	input := `FROM debian:bookworm
+RUN apt-get update && \
+    # install tools
+    apt-get install -y curl && \
+
+    rm -rf /var/lib/apt/lists/*
+CMD ["bash"]
+`
+	d, output := roundTrip(t, input)
+
+	stage := d.FinalStage()
+	if len(stage.Instructions) != 2 {
+		t.Fatalf("got %d instructions, want 2:\n%s", len(stage.Instructions), output)
+	}
+	run := stage.Instructions[0]
+	want := "apt-get update && \\\n    apt-get install -y curl && \\\n    rm -rf /var/lib/apt/lists/*"
+	if run.Keyword != "RUN" || run.Args != want {
+		t.Errorf("got %s %q, want RUN %q", run.Keyword, run.Args, want)
+	}
+	if stage.Instructions[1].Keyword != "CMD" {
+		t.Errorf("got %s after the RUN, want CMD", stage.Instructions[1].Keyword)
+	}
+}
+
+func TestParseContinuationEndingInComment(t *testing.T) {
+	input := "FROM alpine\nRUN echo one \\\n    # the end\nRUN echo two\n"
+	d, _ := roundTrip(t, input)
+
+	// A comment does not end the instruction, the next line continues it
+	instructions := d.FinalStage().Instructions
+	if len(instructions) != 1 || instructions[0].Fields()[len(instructions[0].Fields())-1] != "two" {
+		t.Errorf("got %v, want a single RUN joined with the next line", instructions)
+	}
+}
+
+func TestParseHeredoc(t *testing.T) {
+	input := `FROM alpine
+RUN <<EOF
+set -e
+# not a comment of the Dockerfile
+echo hello
+EOF
+COPY <<-CONF /etc/app.conf
+	key=value
+	CONF
+USER nobody
+`
+	d, output := roundTrip(t, input)
+
+	instructions := d.FinalStage().Instructions
+	if len(instructions) != 3 {
+		t.Fatalf("got %d instructions, want 3:\n%s", len(instructions), output)
+	}
+	run := instructions[0]
+	if len(run.Heredocs) != 1 || run.Heredocs[0].Body != "set -e\n# not a comment of the Dockerfile\necho hello" {
+		t.Errorf("got heredocs %+v", run.Heredocs)
+	}
+	copy := instructions[1]
+	if len(copy.Heredocs) != 1 || !copy.Heredocs[0].Chomp || copy.Heredocs[0].Body != "\tkey=value" {
+		t.Errorf("got heredocs %+v", copy.Heredocs)
+	}
+	if instructions[2].Keyword != "USER" {
+		t.Errorf("got %s after the heredocs, want USER", instructions[2].Keyword)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	input := `# syntax=docker/dockerfile:1
+# check=skip=all
+# not a directive
+FROM alpine
+`
+	d, output := roundTrip(t, input)
+
+	if d.Directive("syntax") != "docker/dockerfile:1" || d.Directive("check") != "skip=all" {
+		t.Errorf("got directives %+v", d.Directives)
+	}
+	if comments := d.FinalStage().Comments; len(comments) != 1 || comments[0] != "not a directive" {
+		t.Errorf("got stage comments %q", comments)
+	}
+	if !strings.HasPrefix(output, "# syntax=docker/dockerfile:1\n# check=skip=all\n\n") {
+		t.Errorf("directives not written first:\n%s", output)
+	}
+}
+
+func TestParseDirectiveAfterBlankLine(t *testing.T) {
+	d, _ := roundTrip(t, "\n# syntax=docker/dockerfile:1\nFROM alpine\n")
+	if len(d.Directives) != 0 {
+		t.Errorf("got directives %+v, want a comment", d.Directives)
+	}
+}
+
+func TestParseLowercaseKeywords(t *testing.T) {
+	input := `arg VERSION=18
+from node:${VERSION} as build
+workdir /app
+copy --from=build --chown=node /app /app
+run npm ci
+`
+	d, output := roundTrip(t, input)
+
+	if len(d.Args) != 1 || d.Args[0].Keyword != "ARG" {
+		t.Errorf("got global args %v", d.Args)
+	}
+	stage := d.Stage("build")
+	if stage == nil || stage.Image != "node:${VERSION}" {
+		t.Fatalf("stage build not parsed:\n%s", output)
+	}
+	copy := stage.Instructions[1]
+	if copy.Keyword != "COPY" || copy.Flag("from") != "build" || copy.Flag("chown") != "node" || copy.Args != "/app /app" {
+		t.Errorf("got %s %v %q", copy.Keyword, copy.Flags, copy.Args)
+	}
+	if !strings.Contains(output, "FROM node:${VERSION} AS build\nWORKDIR /app\n") {
+		t.Errorf("keywords not upper-cased:\n%s", output)
+	}
+}
+
+func TestParseBeforeFrom(t *testing.T) {
+	if _, err := Parse(strings.NewReader("RUN true\nFROM alpine\n")); err == nil {
+		t.Error("expected an error for RUN before FROM")
+	}
+}
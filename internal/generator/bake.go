@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// DefaultPlatforms are built when no platform is given
+var DefaultPlatforms = []string{"linux/amd64", "linux/arm64"}
+
+var (
+	// platformRe matches os/arch[/variant] platform specifiers
+	platformRe = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+(/[a-z0-9]+)?$`)
+	// imageNameRe matches the characters image repository names cannot hold
+	imageNameRe = regexp.MustCompile(`[^a-z0-9._/-]+`)
+)
+
+// bakeAttribute is an attribute of a bake block, with its HCL value
+type bakeAttribute struct {
+	name  string
+	value string
+}
+
+// GenerateBake writes a docker-bake.hcl that builds the app image for
+// several platforms with `docker buildx bake`. An existing file is kept.
+func GenerateBake(project *analyzer.ProjectType, outputPath string) error {
+	bakePath := filepath.Join(outputPath, "docker-bake.hcl")
+	if _, err := os.Stat(bakePath); err == nil {
+		return fmt.Errorf("docker-bake.hcl already exists, leaving it unchanged")
+	}
+
+	platforms := project.Platforms
+	if len(platforms) == 0 {
+		platforms = DefaultPlatforms
+	}
+	for _, platform := range platforms {
+		if !platformRe.MatchString(platform) {
+			return fmt.Errorf("invalid platform %q, expected os/arch such as linux/arm64", platform)
+		}
+	}
+
+	// Workspace packages are built from the workspace root, like in compose
+	context, dockerfilePath := ".", "Dockerfile"
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		context, dockerfilePath = workspace.Root, workspace.Package+"/Dockerfile"
+	}
+
+	variables := []bakeAttribute{
+		{"IMAGE", hclString(imageName(project))},
+		{"TAG", hclString("latest")},
+	}
+	target := []bakeAttribute{
+		{"context", hclString(filepath.ToSlash(context))},
+		{"dockerfile", hclString(dockerfilePath)},
+		{"platforms", hclList(platforms)},
+		{"tags", `["${IMAGE}:${TAG}"]`},
+	}
+	if len(project.Secrets) > 0 {
+		var secrets []string
+		for _, secret := range project.Secrets {
+			if secret.Env != "" {
+				secrets = append(secrets, "id="+secret.ID+",env="+secret.Env)
+			} else {
+				secrets = append(secrets, "id="+secret.ID+",src="+secret.File)
+			}
+		}
+		target = append(target, bakeAttribute{"secret", hclList(secrets)})
+	}
+
+	// The label build args default to the environment variables of the same
+	// name, e.g. REVISION=$(git rev-parse HEAD) docker buildx bake
+	var args []bakeAttribute
+	if df, err := dockerfile.ParseFile(filepath.Join(outputPath, "Dockerfile")); err == nil && hasLabelArgs(df) {
+		for _, arg := range labelArgs {
+			variables = append(variables, bakeAttribute{arg, hclString("")})
+			args = append(args, bakeAttribute{arg, arg})
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Multi-platform image build, run with `docker buildx bake --push`.\n")
+	b.WriteString("# Build for the local platform only with\n")
+	b.WriteString("# `docker buildx bake --set app.platform=" + platforms[0] + " --load`.\n")
+	for _, variable := range variables {
+		b.WriteString("\nvariable \"" + variable.name + "\" {\n")
+		b.WriteString("  default = " + variable.value + "\n")
+		b.WriteString("}\n")
+	}
+	b.WriteString("\ngroup \"default\" {\n  targets = [\"app\"]\n}\n")
+	b.WriteString("\ntarget \"app\" {\n")
+	writeBakeAttributes(&b, target, "  ")
+	if len(args) > 0 {
+		b.WriteString("  args = {\n")
+		writeBakeAttributes(&b, args, "    ")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+
+	return os.WriteFile(bakePath, []byte(b.String()), 0644)
+}
+
+// writeBakeAttributes writes attributes with their equal signs aligned, as
+// HCL formatters do
+func writeBakeAttributes(b *strings.Builder, attributes []bakeAttribute, indent string) {
+	width := 0
+	for _, attribute := range attributes {
+		width = max(width, len(attribute.name))
+	}
+	for _, attribute := range attributes {
+		b.WriteString(fmt.Sprintf("%s%-*s = %s\n", indent, width, attribute.name, attribute.value))
+	}
+}
+
+// imageName derives the image repository from the project's name
+func imageName(project *analyzer.ProjectType) string {
+	name := ""
+	if project.Metadata != nil {
+		name = project.Metadata.Title
+	}
+	name = strings.Trim(imageNameRe.ReplaceAllString(strings.ToLower(strings.TrimPrefix(name, "@")), "-"), "-./")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+// hclString quotes a string for HCL, where ${ starts an interpolation
+func hclString(value string) string {
+	return strings.ReplaceAll(strconv.Quote(value), "${", "$${")
+}
+
+// hclList renders a list of strings
+func hclList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = hclString(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
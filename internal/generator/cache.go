@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// cacheMounts are the download and build caches of package managers, keyed
+// by the command that fills them. Build stages run as root, so the caches
+// live in root's home unless the image moves them.
+var cacheMounts = []struct {
+	command *regexp.Regexp
+	targets []string
+}{
+	{regexp.MustCompile(`\bnpm (install|ci)\b`), []string{"/root/.npm"}},
+	{regexp.MustCompile(`\byarn workspaces focus\b`), []string{"/root/.yarn/berry/cache"}},
+	{regexp.MustCompile(`\byarn install\b`), []string{"/usr/local/share/.cache/yarn"}},
+	{regexp.MustCompile(`\bpnpm install\b`), []string{"/root/.local/share/pnpm/store"}},
+	{regexp.MustCompile(`\bbun install\b`), []string{"/root/.bun/install/cache"}},
+	{regexp.MustCompile(`\bpip install\b`), []string{"/root/.cache/pip"}},
+	{regexp.MustCompile(`\bgo mod download\b`), []string{"/go/pkg/mod"}},
+	{regexp.MustCompile(`\bgo build\b`), []string{"/go/pkg/mod", "/root/.cache/go-build"}},
+	// The composer image sets COMPOSER_HOME to /tmp
+	{regexp.MustCompile(`\bcomposer install\b`), []string{"/tmp/cache"}},
+	{regexp.MustCompile(`\bdotnet restore\b`), []string{"/root/.nuget/packages"}},
+	{regexp.MustCompile(`\bmix deps\.get\b`), []string{"/root/.hex/packages"}},
+}
+
+// addCacheMounts keeps package manager caches between builds with BuildKit
+// cache mounts, so a changed lockfile only downloads what changed. It
+// reports whether any mount was added.
+func addCacheMounts(df *dockerfile.Dockerfile) bool {
+	added := false
+	for _, stage := range df.Stages {
+		for _, instruction := range stage.Instructions {
+			if instruction.Keyword != "RUN" {
+				continue
+			}
+			for _, cache := range cacheMounts {
+				if !cache.command.MatchString(instruction.Args) {
+					continue
+				}
+				for _, target := range cache.targets {
+					if hasMount(instruction, target) {
+						continue
+					}
+					instruction.Flags = append(instruction.Flags, "--mount=type=cache,target="+target)
+					added = true
+				}
+			}
+		}
+	}
+	return added
+}
+
+// hasMount reports whether the instruction already mounts something at target
+func hasMount(instruction *dockerfile.Instruction, target string) bool {
+	for _, flag := range instruction.Flags {
+		if strings.HasPrefix(flag, "--mount=") && strings.Contains(flag+",", ",target="+target+",") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,27 +1,34 @@
 package generator
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
 
 	"gopkg.in/yaml.v3"
 )
 
 // ComposeConfig represents the structure of a docker-compose.yml file
 type ComposeConfig struct {
-	Version  string             `yaml:"version"`
-	Services map[string]Service `yaml:"services"`
-	Networks map[string]Network `yaml:"networks,omitempty"`
-	Volumes  map[string]Volume  `yaml:"volumes,omitempty"`
+	Version  string                   `yaml:"version"`
+	Services map[string]Service       `yaml:"services"`
+	Networks map[string]Network       `yaml:"networks,omitempty"`
+	Volumes  map[string]Volume        `yaml:"volumes,omitempty"`
+	Secrets  map[string]ComposeSecret `yaml:"secrets,omitempty"`
 }
 
 // Service represents a service in docker-compose.yml
 type Service struct {
 	Build       *Build        `yaml:"build,omitempty"`
 	Image       string        `yaml:"image,omitempty"`
+	Command     string        `yaml:"command,omitempty"`
 	Ports       []string      `yaml:"ports,omitempty"`
 	Environment []string      `yaml:"environment,omitempty"`
 	EnvFile     []string      `yaml:"env_file,omitempty"`
@@ -31,6 +38,37 @@ type Service struct {
 	Restart     string        `yaml:"restart,omitempty"`
 	HealthCheck *HealthCheck  `yaml:"healthcheck,omitempty"`
 	Deploy      *DeployConfig `yaml:"deploy,omitempty"`
+	// Profiles only start the service when one of them is enabled
+	Profiles []string `yaml:"profiles,omitempty"`
+	// Tmpfs mounts directories in memory, discarded with the container
+	Tmpfs []string `yaml:"tmpfs,omitempty"`
+	// DependsOnCondition makes the service wait for its dependencies to
+	// reach a condition such as service_healthy, not only to start
+	DependsOnCondition string `yaml:"-"`
+}
+
+// MarshalYAML writes depends_on in the long syntax when the service waits
+// for a condition
+func (s Service) MarshalYAML() (interface{}, error) {
+	type plain Service
+	if s.DependsOnCondition == "" || len(s.DependsOn) == 0 {
+		return plain(s), nil
+	}
+	conditions := make(map[string]map[string]string)
+	for _, dependency := range s.DependsOn {
+		conditions[dependency] = map[string]string{"condition": s.DependsOnCondition}
+	}
+	s.DependsOn = nil
+
+	var node, dependsOn yaml.Node
+	if err := node.Encode(plain(s)); err != nil {
+		return nil, err
+	}
+	if err := dependsOn.Encode(conditions); err != nil {
+		return nil, err
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "depends_on"}, &dependsOn)
+	return &node, nil
 }
 
 // Build represents build configuration
@@ -38,6 +76,9 @@ type Build struct {
 	Context    string            `yaml:"context"`
 	Dockerfile string            `yaml:"dockerfile"`
 	Args       map[string]string `yaml:"args,omitempty"`
+	Secrets    []string          `yaml:"secrets,omitempty"`
+	// Target is the stage to build, the last one if empty
+	Target string `yaml:"target,omitempty"`
 }
 
 // Network represents network configuration
@@ -52,10 +93,13 @@ type Volume struct {
 
 // HealthCheck represents healthcheck configuration
 type HealthCheck struct {
-	Test     []string `yaml:"test"`
-	Interval string   `yaml:"interval"`
-	Timeout  string   `yaml:"timeout"`
-	Retries  int      `yaml:"retries"`
+	Test        []string `yaml:"test,omitempty"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+	// Disable turns off a HEALTHCHECK inherited from the image
+	Disable bool `yaml:"disable,omitempty"`
 }
 
 // DeployConfig represents deployment configuration
@@ -90,27 +134,61 @@ func GenerateCompose(project *analyzer.ProjectType, outputPath string) error {
 			Context:    ".",
 			Dockerfile: "Dockerfile",
 		},
-		Networks: []string{"app-network"},
-		Restart:  "unless-stopped",
-		EnvFile:  []string{".env"},
+		Command:     composeCommand(project.Command),
+		Environment: append([]string(nil), project.Environment...),
+		Networks:    []string{"app-network"},
+		Restart:     "unless-stopped",
+		EnvFile:     []string{".env"},
+	}
+
+	// Workspace packages are built from the workspace root
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		appService.Build.Context = workspace.Root
+		appService.Build.Dockerfile = workspace.Package + "/Dockerfile"
+	}
+
+	// Mirror the image's HEALTHCHECK so compose reports the app's health,
+	// and pass the build args of its labels through from the environment
+	if df, err := dockerfile.ParseFile(filepath.Join(outputPath, "Dockerfile")); err == nil {
+		appService.HealthCheck = composeHealthCheck(df)
+		if hasLabelArgs(df) {
+			appService.Build.Args = composeBuildArgs()
+		}
+	}
+
+	// Private registry credentials are only available during the build
+	addComposeSecrets(compose, &appService, project)
+
+	// Heroku style commands read the port from $PORT
+	if strings.Contains(project.Command, "$PORT") && len(project.Ports) > 0 {
+		appService.Environment = appendEnv(appService.Environment, "PORT", project.Ports[0])
 	}
 
 	// Special handling for Laravel
 	if project.Framework == "laravel" {
-		appService.Volumes = []string{
-			".:/var/www/html",
-		}
-		// Add nginx service for Laravel
+		// Add nginx service for Laravel. The app runs the code of its image,
+		// nginx only needs the public directory to serve static files and find
+		// the front controller.
 		compose.Services["nginx"] = Service{
 			Image: "nginx:alpine",
 			Ports: []string{"80:80"},
 			Volumes: []string{
-				".:/var/www/html",
+				"./public:/var/www/html/public:ro",
 				"./docker/nginx/conf.d:/etc/nginx/conf.d",
 			},
 			Networks:  []string{"app-network"},
 			DependsOn: []string{"app"},
 		}
+		if project.HealthCheck != "" {
+			nginx := compose.Services["nginx"]
+			nginx.HealthCheck = &HealthCheck{
+				Test:     []string{"CMD", "wget", "-q", "--spider", "http://127.0.0.1" + project.HealthCheck},
+				Interval: "30s",
+				Timeout:  "5s",
+				Retries:  3,
+			}
+			compose.Services["nginx"] = nginx
+		}
 
 		// Create nginx config directory and configuration
 		nginxConfigDir := filepath.Join(outputPath, "docker", "nginx", "conf.d")
@@ -141,13 +219,35 @@ func GenerateCompose(project *analyzer.ProjectType, outputPath string) error {
     }
 }`
 
-		if err := os.WriteFile(filepath.Join(nginxConfigDir, "default.conf"), []byte(nginxConfig), 0644); err != nil {
-			return fmt.Errorf("failed to create nginx configuration: %w", err)
+		// Never overwrite a customized nginx configuration when updating in place
+		nginxConfigPath := filepath.Join(nginxConfigDir, "default.conf")
+		if _, err := os.Stat(nginxConfigPath); err != nil || !project.UpdateInPlace {
+			if err := os.WriteFile(nginxConfigPath, []byte(nginxConfig), 0644); err != nil {
+				return fmt.Errorf("failed to create nginx configuration: %w", err)
+			}
 		}
 	} else if len(project.Ports) > 0 {
 		appService.Ports = project.Ports
 	}
 
+	// ASP.NET Core listens on ASPNETCORE_URLS, keep it in sync with the chosen port
+	if project.Framework == "aspnetcore" && len(project.Ports) > 0 {
+		appService.Environment = append(appService.Environment,
+			fmt.Sprintf("ASPNETCORE_URLS=http://+:%s", project.Ports[0]))
+	}
+
+	// Phoenix releases refuse to boot without SECRET_KEY_BASE and build URLs
+	// from PHX_HOST. The generated secret is for local use, production sets
+	// its own in the environment.
+	if project.Framework == "phoenix" {
+		secret, err := secretKeyBase()
+		if err != nil {
+			return err
+		}
+		appService.Environment = appendEnv(appService.Environment, "SECRET_KEY_BASE", "${SECRET_KEY_BASE:-"+secret+"}")
+		appService.Environment = appendEnv(appService.Environment, "PHX_HOST", "${PHX_HOST:-localhost}")
+	}
+
 	compose.Services["app"] = appService
 
 	// Add database service if needed
@@ -161,6 +261,12 @@ func GenerateCompose(project *analyzer.ProjectType, outputPath string) error {
 			// Update app service to depend on database
 			appService := compose.Services["app"]
 			appService.DependsOn = append(appService.DependsOn, dbConfig.Type)
+
+			// Ecto reads the connection from DATABASE_URL in releases
+			if project.Language == "Elixir" && (dbConfig.Type == "postgres" || dbConfig.Type == "mysql") {
+				appService.Environment = append(appService.Environment,
+					fmt.Sprintf("DATABASE_URL=ecto://%s:%s@%s/%s", dbConfig.Username, dbConfig.Password, dbConfig.Type, dbConfig.Database))
+			}
 			compose.Services["app"] = appService
 		}
 	}
@@ -168,60 +274,103 @@ func GenerateCompose(project *analyzer.ProjectType, outputPath string) error {
 	// Add cache service if needed
 	if needsCache(project) {
 		compose.Services["redis"] = createRedisService()
+		compose.Volumes["redis-data"] = Volume{Driver: "local"}
 		appService := compose.Services["app"]
 		appService.DependsOn = append(appService.DependsOn, "redis")
 		compose.Services["app"] = appService
 	}
 
+	// Add message broker if a worker needs RabbitMQ
+	if hasAdditionalService(project, "rabbitmq") {
+		compose.Services["rabbitmq"] = createRabbitMQService()
+		compose.Volumes["rabbitmq-data"] = Volume{Driver: "local"}
+		appService := compose.Services["app"]
+		appService.DependsOn = append(appService.DependsOn, "rabbitmq")
+		compose.Services["app"] = appService
+	}
+
+	// Point variables injected by hosted add-ons at the local services
+	addServiceURLs(compose, project)
+
+	// Background workers and schedulers share the app image and configuration
+	addProcessServices(compose, project)
+
+	// Service images are pinned to the digests of the lock file, if any
+	lock, err := readLock(outputPath)
+	if err != nil {
+		return err
+	}
+	pinCompose(compose, lock)
+
+	// Merge into a hand-written compose file instead of replacing it
+	if project.UpdateInPlace && project.ExistingCompose != nil {
+		return mergeCompose(compose, project.ExistingCompose)
+	}
+
 	data, err := yaml.Marshal(compose)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(filepath.Join(outputPath, "docker-compose.yml"), data, 0644)
+	return os.WriteFile(composeFilePath(project, outputPath), data, 0644)
+}
+
+// composeFilePath returns the compose file the project is run with. An
+// existing one keeps its name when it is regenerated, docker compose only
+// reads the first of ComposeFileNames it finds.
+func composeFilePath(project *analyzer.ProjectType, outputPath string) string {
+	if project.ExistingCompose != nil {
+		return project.ExistingCompose.Path
+	}
+	return filepath.Join(outputPath, "docker-compose.yml")
+}
+
+// defaultDatabases holds the default settings of each database type
+var defaultDatabases = map[string]DatabaseConfig{
+	"postgres": {
+		Type:     "postgres",
+		Version:  "13-alpine",
+		Port:     "5432",
+		Username: "postgres",
+		Password: "postgres",
+		Database: "app",
+	},
+	"mysql": {
+		Type:     "mysql",
+		Version:  "8.0",
+		Port:     "3306",
+		Username: "root",
+		Password: "root",
+		Database: "app",
+	},
+	"mongodb": {
+		Type:     "mongodb",
+		Version:  "4.4",
+		Port:     "27017",
+		Username: "root",
+		Password: "root",
+		Database: "app",
+	},
 }
 
 func getDefaultDBConfig(project *analyzer.ProjectType) *DatabaseConfig {
-	switch project.Framework {
-	case "django", "flask", "fastapi":
-		return &DatabaseConfig{
-			Type:     "postgres",
-			Version:  "13-alpine",
-			Port:     "5432",
-			Username: "postgres",
-			Password: "postgres",
-			Database: "app",
-		}
-	case "rails":
-		return &DatabaseConfig{
-			Type:     "postgres",
-			Version:  "13-alpine",
-			Port:     "5432",
-			Username: "postgres",
-			Password: "postgres",
-			Database: "app",
-		}
-	case "laravel", "symfony":
-		return &DatabaseConfig{
-			Type:     "mysql",
-			Version:  "8.0",
-			Port:     "3306",
-			Username: "root",
-			Password: "root",
-			Database: "app",
+	// A selected or imported database type wins over the framework default
+	dbType := project.Database
+	if _, ok := defaultDatabases[dbType]; !ok {
+		switch project.Framework {
+		case "django", "flask", "fastapi", "rails", "aspnetcore":
+			dbType = "postgres"
+		case "laravel", "symfony":
+			dbType = "mysql"
+		case "express", "nestjs":
+			dbType = "mongodb"
+		default:
+			return nil
 		}
-	case "express", "nestjs":
-		return &DatabaseConfig{
-			Type:     "mongodb",
-			Version:  "4.4",
-			Port:     "27017",
-			Username: "root",
-			Password: "root",
-			Database: "app",
-		}
-	default:
-		return nil
 	}
+
+	config := defaultDatabases[dbType]
+	return &config
 }
 
 func createDatabaseService(config *DatabaseConfig) Service {
@@ -311,9 +460,111 @@ func createRedisService() Service {
 	}
 }
 
+func createRabbitMQService() Service {
+	return Service{
+		Image: "rabbitmq:3-management-alpine",
+		Ports: []string{"5672:5672", "15672:15672"},
+		Volumes: []string{
+			"rabbitmq-data:/var/lib/rabbitmq",
+		},
+		Networks: []string{"app-network"},
+		HealthCheck: &HealthCheck{
+			Test:     []string{"CMD", "rabbitmq-diagnostics", "-q", "ping"},
+			Interval: "10s",
+			Timeout:  "5s",
+			Retries:  5,
+		},
+		Restart: "unless-stopped",
+	}
+}
+
+func hasAdditionalService(project *analyzer.ProjectType, name string) bool {
+	for _, service := range project.AdditionalServices {
+		if service == name {
+			return true
+		}
+	}
+	return false
+}
+
 func needsCache(project *analyzer.ProjectType) bool {
-	return project.Framework == "laravel" ||
+	return hasAdditionalService(project, "redis") ||
+		project.Framework == "laravel" ||
 		project.Framework == "rails" ||
 		project.Framework == "django" ||
 		project.Framework == "nestjs"
 }
+
+// addServiceURLs sets the connection URL variables that hosted add-ons used
+// to inject, pointing them at the services in the compose file
+func addServiceURLs(compose *ComposeConfig, project *analyzer.ProjectType) {
+	if len(project.ServiceURLs) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(project.ServiceURLs))
+	for name := range project.ServiceURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	appService := compose.Services["app"]
+	for _, name := range names {
+		if url := serviceURL(project, project.ServiceURLs[name]); url != "" {
+			appService.Environment = appendEnv(appService.Environment, name, url)
+		}
+	}
+	compose.Services["app"] = appService
+}
+
+// serviceURL returns the connection URL of a generated service
+func serviceURL(project *analyzer.ProjectType, service string) string {
+	if url, ok := brokerURLs[service]; ok {
+		return url
+	}
+
+	dbConfig := getDefaultDBConfig(project)
+	if dbConfig == nil || dbConfig.Type != service {
+		return ""
+	}
+	switch service {
+	case "postgres":
+		return fmt.Sprintf("postgres://%s:%s@postgres:5432/%s", dbConfig.Username, dbConfig.Password, dbConfig.Database)
+	case "mysql":
+		return fmt.Sprintf("mysql://%s:%s@mysql:3306/%s", dbConfig.Username, dbConfig.Password, dbConfig.Database)
+	case "mongodb":
+		return fmt.Sprintf("mongodb://%s:%s@mongodb:27017/%s?authSource=admin", dbConfig.Username, dbConfig.Password, dbConfig.Database)
+	}
+	return ""
+}
+
+// secretKeyBase returns a random secret as long as mix phx.gen.secret makes
+func secretKeyBase() (string, error) {
+	secret := make([]byte, 48)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate SECRET_KEY_BASE: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// appendEnv adds KEY=value unless the variable is already set
+func appendEnv(env []string, key, value string) []string {
+	for _, variable := range env {
+		if strings.SplitN(variable, "=", 2)[0] == key {
+			return env
+		}
+	}
+	return append(env, key+"="+value)
+}
+
+// composeCommand prepares a shell command line for compose. Commands that
+// reference variables run through a shell, with `$` escaped so compose does
+// not interpolate them from the host environment.
+func composeCommand(command string) string {
+	if !strings.Contains(command, "$") {
+		return command
+	}
+	escaped := strings.ReplaceAll(command, "$", "$$")
+	escaped = strings.ReplaceAll(escaped, "'", `'"'"'`)
+	return "sh -c '" + escaped + "'"
+}
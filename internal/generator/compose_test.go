@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/analyzer"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLaravelComposeRunsImage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"composer.json":      `{"require": {"laravel/framework": "^11.0", "laravel/horizon": "^5.0"}}`,
+		"artisan":            "#!/usr/bin/env php\n",
+		"public/index.php":   "<?php\n",
+		"routes/console.php": "<?php\nSchedule::command('inspire')->hourly();\n",
+	})
+	project, err := analyzer.AnalyzeProject(dir)
+	if err != nil {
+		t.Fatalf("AnalyzeProject: %v", err)
+	}
+	if err := GenerateCompose(project, dir); err != nil {
+		t.Fatalf("GenerateCompose: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compose ComposeConfig
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := compose.Services["horizon"]; !ok {
+		t.Errorf("no horizon service:\n%s", data)
+	}
+	// The source is only mounted by the dev override
+	for name, service := range compose.Services {
+		for _, volume := range service.Volumes {
+			if strings.HasPrefix(volume, ".:") {
+				t.Errorf("service %s mounts the source with %s", name, volume)
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// devStage is the name of the development stage
+const devStage = "dev"
+
+// debuggers start the dev command under the language's debugger, listening
+// on port. attach returns nil for commands it cannot debug.
+var debuggers = map[string]struct {
+	name   string
+	port   string
+	attach func(args []string) []string
+}{
+	"Node.js": {"The Node.js inspector", "9229", func(args []string) []string {
+		switch args[0] {
+		case "node":
+			return insertArgs(args, 1, "--inspect=0.0.0.0:9229")
+		case "npm", "npx":
+			// Passed on to the scripts npm runs, not npm itself
+			return insertArgs(args, 1, "--node-options=--inspect=0.0.0.0:9229")
+		}
+		return nil
+	}},
+	"Python": {"debugpy", "5678", func(args []string) []string {
+		debugpy := []string{"python", "-m", "debugpy", "--listen", "0.0.0.0:5678"}
+		if args[0] == "python" || args[0] == "python3" {
+			return append(debugpy, args[1:]...)
+		}
+		return append(append(debugpy, "-m"), args...)
+	}},
+	"Go": {"Delve", "2345", func(args []string) []string {
+		if len(args) < 3 || args[0] != "go" || args[1] != "run" {
+			return nil
+		}
+		// go run's first argument is the package, the rest are the program's.
+		// Delve builds packages, a file names the package of its directory.
+		pkg := args[2]
+		if strings.HasSuffix(pkg, ".go") {
+			pkg = path.Dir(pkg)
+			if !strings.HasPrefix(pkg, ".") {
+				pkg = "./" + pkg
+			}
+		}
+		dlv := []string{"dlv", "debug", "--headless", "--listen=:2345", "--api-version=2", "--accept-multiclient", "--continue", pkg}
+		if len(args) > 3 {
+			dlv = append(append(dlv, "--"), args[3:]...)
+		}
+		return dlv
+	}},
+}
+
+// delveReleases are the newest Delve release supporting each Go minor
+// version, Delve refuses binaries built by Go releases much older than itself
+var delveReleases = []struct {
+	minor   int
+	version string
+}{
+	{19, "v1.21.2"},
+	{20, "v1.22.1"},
+	{21, "v1.23.1"},
+	{22, "v1.24.1"},
+	{23, "v1.25.0"},
+}
+
+// delveVersion returns the Delve release to install for a go.mod go
+// version, the release of the newest Go version listed for later ones
+func delveVersion(goVersion string) string {
+	minor := 21
+	if parts := strings.Split(goVersion, "."); len(parts) > 1 {
+		if n, err := strconv.Atoi(parts[1]); err == nil {
+			minor = n
+		}
+	}
+	for _, release := range delveReleases {
+		if minor <= release.minor {
+			return release.version
+		}
+	}
+	return delveReleases[len(delveReleases)-1].version
+}
+
+// insertArgs returns args with values inserted at index
+func insertArgs(args []string, index int, values ...string) []string {
+	return append(append(append([]string(nil), args[:index]...), values...), args[index:]...)
+}
+
+// addDevStage renders the language's dev template and inserts its stage
+// before the production stage, which stays the default build target
+func addDevStage(df *dockerfile.Dockerfile, tmpl *template.Template, data templateData) error {
+	name := data.LanguageConfig.Templates["dev"]
+	if name == "" || data.FrameworkConfig.DevCommand == "" || len(df.Stages) == 0 {
+		return nil
+	}
+	if tmpl.Lookup(name) == nil {
+		return fmt.Errorf("Dockerfile template %s not found in %s", name, TemplateDir)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&rendered, name, data); err != nil {
+		return fmt.Errorf("failed to generate the dev stage: %w", err)
+	}
+	dev, err := dockerfile.Parse(&rendered)
+	if err != nil {
+		return fmt.Errorf("template %s produced an invalid Dockerfile: %w", name, err)
+	}
+	stage := dev.Stage(devStage)
+	if stage == nil {
+		return fmt.Errorf("template %s has no %s stage", name, devStage)
+	}
+	attachDebugger(stage, data.Language)
+
+	final := df.FinalStage()
+	df.Stages = append(append(df.Stages[:len(df.Stages)-1:len(df.Stages)-1], dev.Stages...), final)
+	return nil
+}
+
+// attachDebugger runs the dev stage's command under the language's debugger
+// and exposes its port
+func attachDebugger(stage *dockerfile.Stage, language string) {
+	debugger, ok := debuggers[language]
+	cmds := stage.Find("CMD")
+	if !ok || len(cmds) == 0 {
+		return
+	}
+	cmd := cmds[len(cmds)-1]
+	args, ok := cmd.ExecArgs()
+	if !ok || len(args) == 0 {
+		return
+	}
+	attached := debugger.attach(args)
+	if attached == nil {
+		return
+	}
+	cmd.Args = dockerfile.ExecForm(attached...)
+	stage.Insert(stage.Index("CMD"), &dockerfile.Instruction{
+		Comments: []string{debugger.name + " listens on port " + debugger.port},
+		Keyword:  "EXPOSE",
+		Args:     debugger.port,
+	})
+}
+
+// GenerateComposeOverride writes the override file docker compose applies
+// on top of the compose file: the app runs the dev stage with the source
+// bind-mounted, dependency directories kept in volumes and the dev server and
+// debugger ports published. An existing override file is kept.
+func GenerateComposeOverride(project *analyzer.ProjectType, outputPath string) error {
+	composePath := composeFilePath(project, outputPath)
+	overridePath := ComposeOverridePath(project, outputPath)
+	if _, err := os.Stat(overridePath); err == nil {
+		return fmt.Errorf("%s already exists, leaving it unchanged", filepath.Base(overridePath))
+	}
+
+	df, err := dockerfile.ParseFile(filepath.Join(outputPath, "Dockerfile"))
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	stage := df.Stage(devStage)
+	if stage == nil || len(stage.Find("WORKDIR")) == 0 || len(stage.Find("CMD")) == 0 {
+		return fmt.Errorf("the Dockerfile has no %s stage, skipping %s", devStage, filepath.Base(overridePath))
+	}
+	language, err := analyzer.FindLanguageConfig(project.Language)
+	if err != nil {
+		return err
+	}
+
+	// The build context is mounted where the dev stage copied it
+	build := &Build{Context: ".", Dockerfile: "Dockerfile", Target: devStage}
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		build.Context = workspace.Root
+		build.Dockerfile = workspace.Package + "/Dockerfile"
+	}
+	workdirs := stage.Find("WORKDIR")
+	root, workdir := workdirs[0].Args, workdirs[len(workdirs)-1].Args
+	app := Service{
+		Build:       build,
+		Volumes:     []string{build.Context + ":" + root},
+		Environment: language.Frameworks[project.Framework].DevEnvironment,
+	}
+
+	// Dependencies installed in the image would be hidden by the mount
+	var dirs []string
+	for _, dir := range language.DevVolumes {
+		dirs = append(dirs, path.Join(workdir, dir))
+		if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+			dirs = append(dirs, path.Join(root, dir))
+			for _, member := range workspace.Members {
+				dirs = append(dirs, path.Join(root, member, dir))
+			}
+		}
+	}
+	for _, dir := range dirs {
+		if !containsString(app.Volumes, dir) {
+			app.Volumes = append(app.Volumes, dir)
+		}
+	}
+
+	// Compose appends ports to the ones the compose file publishes already
+	services := composeServices(composePath)
+	for _, expose := range stage.Find("EXPOSE") {
+		for _, port := range expose.Fields() {
+			if mapping := port + ":" + port; !containsString(services["app"], mapping) {
+				app.Ports = append(app.Ports, mapping)
+			}
+		}
+	}
+
+	// A command set for the app service would replace the dev stage's
+	if project.Command != "" {
+		cmds := stage.Find("CMD")
+		if args, ok := cmds[len(cmds)-1].ExecArgs(); ok {
+			app.Command = shellJoin(args)
+		}
+	}
+
+	// The production HEALTHCHECK does not apply to the dev server
+	if composeHealthCheck(df) != nil {
+		app.HealthCheck = &HealthCheck{Disable: true}
+	}
+
+	override := &ComposeConfig{
+		Version:  "3.8",
+		Services: map[string]Service{"app": app},
+	}
+
+	// Laravel's dev server replaces php-fpm behind nginx, which then only
+	// starts with `docker compose --profile nginx up`
+	if project.Framework == "laravel" && services["nginx"] != nil {
+		override.Services["nginx"] = Service{Profiles: []string{"nginx"}}
+	}
+
+	data, err := yaml.Marshal(override)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(overridePath, data, 0644)
+}
+
+// ComposeOverridePath returns the override file docker compose picks up
+// next to the project's compose file
+func ComposeOverridePath(project *analyzer.ProjectType, outputPath string) string {
+	composePath := composeFilePath(project, outputPath)
+	ext := filepath.Ext(composePath)
+	return strings.TrimSuffix(composePath, ext) + ".override" + ext
+}
+
+// composeServices returns the ports each service of a compose file
+// publishes, in the short syntax
+func composeServices(composePath string) map[string][]string {
+	services := make(map[string][]string)
+	data, err := ioutil.ReadFile(composePath)
+	if err != nil {
+		return services
+	}
+	var compose struct {
+		Services map[string]struct {
+			Ports []interface{} `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	if yaml.Unmarshal(data, &compose) == nil {
+		for name, service := range compose.Services {
+			ports := []string{}
+			for _, port := range service.Ports {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			services[name] = ports
+		}
+	}
+	return services
+}
+
+// shellJoin joins command arguments, quoting those the shell would split
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$`\\*?;&|<>(){}") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
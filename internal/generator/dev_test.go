@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// devCommand returns the command and ports of the dev stage
+func devCommand(t *testing.T, df *dockerfile.Dockerfile) ([]string, []string) {
+	t.Helper()
+	stage := df.Stage(devStage)
+	if stage == nil {
+		t.Fatalf("no %s stage:\n%s", devStage, df)
+	}
+	cmds := stage.Find("CMD")
+	args, _ := cmds[len(cmds)-1].ExecArgs()
+	var ports []string
+	for _, expose := range stage.Find("EXPOSE") {
+		ports = append(ports, expose.Fields()...)
+	}
+	return args, ports
+}
+
+func TestDevServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		command string
+		port    string
+	}{
+		{
+			name: "react-scripts",
+			files: map[string]string{
+				"package.json": `{"scripts": {"start": "react-scripts start", "build": "react-scripts build"},
+					"dependencies": {"react": "18.2.0", "react-scripts": "5.0.1"}}`,
+			},
+			command: "start",
+			port:    "3000",
+		},
+		{
+			name: "react with vite",
+			files: map[string]string{
+				"package.json": `{"scripts": {"dev": "vite", "build": "vite build"},
+					"dependencies": {"react": "18.2.0"}, "devDependencies": {"vite": "5.0.0"}}`,
+				"vite.config.ts": "export default defineConfig({ plugins: [react()] })\n",
+			},
+			command: "run dev -- --host 0.0.0.0",
+			port:    "5173",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, ports := devCommand(t, generate(t, tt.files))
+			if got := shellJoin(args[2:]); args[0] != "npm" || got != tt.command {
+				t.Errorf("dev stage runs %q, want npm %s", args, tt.command)
+			}
+			if !containsString(ports, tt.port) {
+				t.Errorf("dev stage exposes %v, want %s", ports, tt.port)
+			}
+		})
+	}
+}
+
+func TestGoDebugger(t *testing.T) {
+	df := generate(t, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22.5\n\nrequire github.com/gin-gonic/gin v1.9.1\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	if got := df.Stage("builder").Image; got != "golang:1.22.5-alpine" {
+		t.Errorf("builder stage runs %s, want the go directive's golang:1.22.5-alpine", got)
+	}
+	if got := df.Stage(devStage).Image; got != "golang:1.22.5-alpine" {
+		t.Errorf("dev stage runs %s, want the go directive's golang:1.22.5-alpine", got)
+	}
+	if run := df.Stage(devStage).Instructions[0]; !strings.HasSuffix(run.Args, "dlv@v1.24.1") {
+		t.Errorf("dev stage starts with %s, want Delve pinned to v1.24.1", run)
+	}
+
+	// Delve builds the whole package, not only the file go run was given
+	args, _ := devCommand(t, df)
+	if args[0] != "dlv" || args[len(args)-1] != "." {
+		t.Errorf("dev stage runs %q, want dlv debug of the package", args)
+	}
+}
+
+func TestDelveVersion(t *testing.T) {
+	for goVersion, want := range map[string]string{
+		"1.18":   "v1.21.2",
+		"1.21":   "v1.23.1",
+		"1.23.4": "v1.25.0",
+		"1.26":   "v1.25.0",
+		"":       "v1.23.1",
+	} {
+		if got := delveVersion(goVersion); got != want {
+			t.Errorf("delveVersion(%q) = %s, want %s", goVersion, got, want)
+		}
+	}
+}
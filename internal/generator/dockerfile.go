@@ -1,158 +1,30 @@
 package generator
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/template"
 
 	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
 )
 
-// DockerfileTemplate represents the basic structure for a Dockerfile
-const DockerfileTemplate = `{{ if eq .Language "Node.js" }}
-# Build stage
-FROM node:18-alpine AS builder
-WORKDIR /app
-COPY package*.json ./
-RUN npm install
-COPY . .
-{{ if eq .Framework "nextjs" }}
-RUN npm run build
-{{ else if eq .Framework "react" }}
-RUN npm run build
-{{ else if eq .Framework "angular" }}
-RUN npm run build --prod
-{{ end }}
-
-# Production stage
-FROM node:18-alpine
-WORKDIR /app
-{{ if eq .Framework "nextjs" }}
-COPY --from=builder /app/.next ./.next
-COPY --from=builder /app/public ./public
-COPY --from=builder /app/package*.json ./
-COPY --from=builder /app/node_modules ./node_modules
-{{ else if eq .Framework "react" }}
-COPY --from=builder /app/build ./build
-COPY --from=builder /app/package*.json ./
-RUN npm install --production
-{{ else if eq .Framework "angular" }}
-COPY --from=builder /app/dist ./dist
-RUN npm install -g serve
-{{ else }}
-COPY --from=builder /app .
-{{ end }}
-
-{{ if eq .Framework "nextjs" }}
-CMD ["npm", "start"]
-{{ else if eq .Framework "react" }}
-CMD ["npm", "start"]
-{{ else if eq .Framework "angular" }}
-CMD ["serve", "-s", "dist"]
-{{ else }}
-CMD ["node", "index.js"]
-{{ end }}
-
-{{ else if eq .Language "PHP" }}
-# Build stage
-FROM composer:latest AS builder
-WORKDIR /app
-COPY composer.json composer.lock ./
-RUN composer install --no-dev --optimize-autoloader
-
-# Production stage
-FROM php:8.2-fpm
-WORKDIR /var/www/html
-
-# Install system dependencies
-RUN apt-get update && apt-get install -y \
-    git \
-    curl \
-    libpng-dev \
-    libonig-dev \
-    libxml2-dev \
-    zip \
-    unzip
-
-# Clear cache
-RUN apt-get clean && rm -rf /var/lib/apt/lists/*
-
-# Install PHP extensions
-RUN docker-php-ext-install pdo_mysql mbstring exif pcntl bcmath gd
-
-# Copy composer dependencies
-COPY --from=builder /app/vendor ./vendor
-
-# Copy application files
-COPY . .
-
-{{ if eq .Framework "laravel" }}
-# Set Laravel storage permissions
-RUN chown -R www-data:www-data \
-    storage \
-    bootstrap/cache \
-    vendor
-
-# Set Laravel environment
-ENV APP_ENV=production
-ENV APP_DEBUG=false
-
-# Expose port
-EXPOSE {{ index .Ports 0 }}
-
-# Start PHP-FPM
-CMD ["php-fpm"]
-{{ else }}
-EXPOSE 9000
-CMD ["php-fpm"]
-{{ end }}
-
-{{ else if eq .Language "Python" }}
-# Build stage
-FROM python:3.9-slim AS builder
-WORKDIR /app
-COPY requirements.txt .
-RUN pip install --user -r requirements.txt
-
-# Production stage
-FROM python:3.9-slim
-WORKDIR /app
-COPY --from=builder /root/.local /root/.local
-COPY . .
-ENV PATH=/root/.local/bin:$PATH
-
-{{ if eq .Framework "django" }}
-EXPOSE 8000
-CMD ["python", "manage.py", "runserver", "0.0.0.0:8000"]
-{{ else if eq .Framework "flask" }}
-EXPOSE 5000
-CMD ["flask", "run", "--host=0.0.0.0"]
-{{ else if eq .Framework "fastapi" }}
-EXPOSE 8000
-CMD ["uvicorn", "main:app", "--host", "0.0.0.0", "--port", "8000"]
-{{ else }}
-CMD ["python", "app.py"]
-{{ end }}
-
-{{ else if eq .Language "Go" }}
-# Build stage
-FROM golang:1.21-alpine AS builder
-WORKDIR /app
-COPY go.* ./
-RUN go mod download
-COPY . .
-RUN CGO_ENABLED=0 GOOS=linux go build -a -installsuffix cgo -o main .
-
-# Production stage
-FROM alpine:latest
-RUN apk --no-cache add ca-certificates
-WORKDIR /root/
-COPY --from=builder /app/main .
-{{ range .Ports }}
-EXPOSE {{ . }}
-{{ end }}
-CMD ["./main"]
-{{ end }}`
+// TemplateDir holds the catalog's Dockerfile templates
+const TemplateDir = "supported/templates"
+
+// templateData is what Dockerfile templates are rendered with: the analyzed
+// project together with its language and framework catalog entries
+type templateData struct {
+	*analyzer.ProjectType
+	LanguageConfig  *analyzer.LanguageConfig
+	FrameworkConfig analyzer.FrameworkConfig
+	// Runtime is the flavor of the production stage, nil for languages
+	// without flavors
+	Runtime *analyzer.RuntimeFlavor
+}
 
 // GenerateDockerfile creates a Dockerfile based on project analysis
 func GenerateDockerfile(project *analyzer.ProjectType, outputPath string) error {
@@ -161,50 +33,223 @@ func GenerateDockerfile(project *analyzer.ProjectType, outputPath string) error
 		return fmt.Errorf("language not detected")
 	}
 
-	// Check if language is supported
-	supportedLanguages := map[string]bool{
-		"Node.js": true,
-		"PHP":     true,
-		"Python":  true,
-		"Go":      true,
+	// Keep hand-written Dockerfiles when asked to update them in place
+	if project.UpdateInPlace && project.ExistingDockerfile != nil {
+		if err := updateDockerfile(project); err != nil {
+			return fmt.Errorf("failed to update Dockerfile: %w", err)
+		}
+		return nil
 	}
 
-	if !supportedLanguages[project.Language] {
-		return fmt.Errorf("unsupported language: %s", project.Language)
+	language, err := analyzer.FindLanguageConfig(project.Language)
+	if err != nil {
+		return err
+	}
+	flavor, err := language.RuntimeFlavor(project.Flavor)
+	if err != nil {
+		return err
+	}
+	name := templateName(project, language)
+	if name == "" {
+		return fmt.Errorf("no Dockerfile template for %s framework %s", project.Language, project.Framework)
 	}
 
-	// For PHP, ensure framework is Laravel
-	if project.Language == "PHP" && project.Framework != "laravel" {
-		return fmt.Errorf("unsupported PHP framework: %s", project.Framework)
+	// .NET builds need the project file to restore and publish
+	if project.Language == ".NET" && project.ProjectFile == "" {
+		return fmt.Errorf("no .csproj project file found")
 	}
 
-	tmpl, err := template.New("dockerfile").Parse(DockerfileTemplate)
-	if err != nil {
+	// Elixir releases are named after the OTP application
+	if project.Language == "Elixir" && project.AppName == "" {
+		return fmt.Errorf("could not determine the OTP application name from mix.exs")
+	}
+
+	// Deno and Bun run a module directly
+	if (project.Language == "Deno" || project.Language == "Bun") && project.EntryPoint == "" {
+		return fmt.Errorf("could not determine the %s entry module", project.Language)
+	}
+
+	// SvelteKit needs an adapter that produces something runnable
+	if project.Framework == "sveltekit" && !project.StaticSite && project.EntryPoint == "" {
+		return fmt.Errorf("SvelteKit needs @sveltejs/adapter-node or @sveltejs/adapter-static to run in a container")
+	}
+
+	// Secrets are mounted by BuildKit, the legacy builder cannot pass them
+	if project.LegacyBuilder && len(project.Secrets) > 0 {
+		return fmt.Errorf("private registry credentials are passed as BuildKit secrets, which the legacy builder does not support")
+	}
+
+	// Credentials must stay out of the build context
+	if err := ignoreSecretFiles(project, outputPath); err != nil {
 		return err
 	}
 
-	// Create Dockerfile
-	file, err := os.Create(outputPath + "/Dockerfile")
+	// Static sites are served by nginx with a generated configuration
+	if project.StaticSite {
+		if err := writeStaticSiteConfig(project, outputPath); err != nil {
+			return err
+		}
+	}
+
+	// Python web apps run in gunicorn with a generated configuration
+	if project.AppServer != nil {
+		if err := writeGunicornConfig(project, language.Frameworks[project.Framework].Port, outputPath); err != nil {
+			return err
+		}
+	}
+
+	funcs := template.FuncMap{
+		"dir": func(path string) string {
+			return filepath.ToSlash(filepath.Dir(path))
+		},
+		"join":             strings.Join,
+		"execForm":         execForm,
+		"workspaceInstall": workspaceInstall,
+		"workspaceBuild":   workspaceBuild,
+		"secretRun":        secretRun,
+		"hasPrefix":        strings.HasPrefix,
+		"delveVersion":     delveVersion,
+	}
+
+	tmpl, err := template.New("dockerfile").Funcs(funcs).ParseGlob(filepath.Join(TemplateDir, "*.Dockerfile"))
+	if err != nil {
+		return fmt.Errorf("failed to load Dockerfile templates: %w", err)
+	}
+	if tmpl.Lookup(name) == nil {
+		return fmt.Errorf("Dockerfile template %s not found in %s", name, TemplateDir)
+	}
+
+	// Render the template and read it into the Dockerfile model
+	var rendered bytes.Buffer
+	data := templateData{
+		ProjectType:     project,
+		LanguageConfig:  language,
+		FrameworkConfig: language.Frameworks[project.Framework],
+		Runtime:         flavor,
+	}
+	// Apps built with another framework's tooling run its dev server
+	if dev, ok := language.Frameworks[project.DevServer]; ok {
+		data.FrameworkConfig.DevCommand = dev.DevCommand
+		data.FrameworkConfig.DevPort = dev.DevPort
+		if dev.DevPort == 0 {
+			data.FrameworkConfig.DevPort = dev.Port
+		}
+	}
+	// Dev servers load the catalog's default app, point them at the one found
+	if server := data.FrameworkConfig.Server; server != nil && project.AppServer != nil {
+		data.FrameworkConfig.DevCommand = strings.Replace(data.FrameworkConfig.DevCommand, server.App, project.AppServer.App, 1)
+	}
+	if err := tmpl.ExecuteTemplate(&rendered, name, data); err != nil {
+		return fmt.Errorf("failed to generate Dockerfile: %w", err)
+	}
+	df, err := dockerfile.Parse(&rendered)
 	if err != nil {
+		return fmt.Errorf("template %s produced an invalid Dockerfile: %w", name, err)
+	}
+	if err := addDevStage(df, tmpl, data); err != nil {
 		return err
 	}
-	defer file.Close()
+	addTestStage(df, project)
+	df.Tidy()
+
+	// Flavors apply to templates whose production stage is the runtime image
+	if flavor != nil && df.FinalStage() != nil && df.FinalStage().Image != flavor.Image {
+		if project.Flavor != "" {
+			return fmt.Errorf("template %s does not support runtime flavors", name)
+		}
+		flavor = nil
+	}
+	if err := addNativeBuild(df, project, language, flavor); err != nil {
+		return err
+	}
+	applyRuntimeUser(df, project, data.FrameworkConfig, flavor)
+	if err := applyRuntimeFlavor(df, flavor); err != nil {
+		return err
+	}
+	addHealthCheck(df, project, flavor)
+	addLabels(df, project)
+	if err := checkShell(df, flavor); err != nil {
+		return err
+	}
+
+	// Cache mounts and secrets need BuildKit and the Dockerfile 1.x syntax,
+	// and only BuildKit sets BUILDPLATFORM. The legacy builder gets plain RUN
+	// instructions and builds every stage on the target platform.
+	cached := false
+	if project.LegacyBuilder {
+		dropBuildPlatform(df)
+	} else {
+		cached = addCacheMounts(df)
+	}
+	if cached || len(project.Secrets) > 0 {
+		df.SetDirective("syntax", "docker/dockerfile:1")
+	}
 
-	// Execute template with project data
-	err = tmpl.Execute(file, project)
+	// Base images are pinned to the digests of the lock file, if any
+	lock, err := readLock(outputPath)
 	if err != nil {
-		// If template execution fails, remove the empty or partial Dockerfile
-		os.Remove(outputPath + "/Dockerfile")
-		return fmt.Errorf("failed to generate Dockerfile: %w", err)
+		return err
 	}
+	pinDockerfile(df, lock)
 
-	// Verify the file is not empty
-	fileInfo, err := file.Stat()
-	if err != nil || fileInfo.Size() == 0 {
-		os.Remove(outputPath + "/Dockerfile")
-		return fmt.Errorf("generated Dockerfile is empty, template conditions not met")
+	if err := df.Validate(); err != nil {
+		return fmt.Errorf("template %s produced an invalid Dockerfile: %w", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, "Dockerfile"), []byte(dockerfile.Format(df)), 0644); err != nil {
+		return err
 	}
 
 	fmt.Println("Successfully generated Dockerfile with multi-stage build support")
 	return nil
-} 
\ No newline at end of file
+}
+
+// templateName picks the catalog template for a project. Build variants
+// that change the whole image (workspace packages, static sites, servers
+// built into an entry module) win over the framework's own template.
+func templateName(project *analyzer.ProjectType, language *analyzer.LanguageConfig) string {
+	switch {
+	case project.Workspace != nil && project.Workspace.Name != "" && language.Templates["workspace"] != "":
+		return language.Templates["workspace"]
+	case project.StaticSite && language.Templates["static"] != "":
+		return language.Templates["static"]
+	case project.EntryPoint != "" && language.Templates["server"] != "":
+		return language.Templates["server"]
+	}
+	if framework, ok := language.Frameworks[project.Framework]; ok && framework.Template != "" {
+		return framework.Template
+	}
+	return language.Templates["default"]
+}
+
+// execForm converts a shell command from the catalog to the JSON exec form
+// used by CMD, honoring single and double quotes
+func execForm(command string) string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return dockerfile.ExecForm(args...)
+}
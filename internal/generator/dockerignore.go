@@ -0,0 +1,316 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// commonIgnores are left out of every build context
+var commonIgnores = []string{
+	".git",
+	"**/.DS_Store",
+	".idea",
+	".vscode",
+	"**/*.log",
+	"**/.env",
+	"**/.env.*",
+	"!**/.env.example",
+	"Dockerfile",
+	".dockerignore",
+	"docker-compose*.yml",
+	"docker-bake.hcl",
+	"compose.test.yml",
+	"dockerizer.lock",
+}
+
+// secretFileRe matches file names that usually hold credentials
+var secretFileRe = regexp.MustCompile(`(?i)^(\.env(\..+)?|id_(rsa|dsa|ecdsa|ed25519)|\.netrc|\.pgpass|credentials(\.json)?|.*service[-_]?account.*\.json|.*\.(pem|key|p12|pfx|jks|keystore))$`)
+
+// secretExamples look like credentials but are meant to be shared
+var secretExamples = regexp.MustCompile(`(?i)\.(example|sample|template|dist)$`)
+
+// ignoreRule is a compiled .dockerignore pattern
+type ignoreRule struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// GenerateDockerignore writes or extends the .dockerignore of the build
+// context with common rules, the language's rules from the catalog and the
+// project's .gitignore. It returns warnings about files the Dockerfile
+// copies although they are ignored, and about credentials it would copy.
+func GenerateDockerignore(project *analyzer.ProjectType, outputPath string) ([]string, error) {
+	language, err := analyzer.FindLanguageConfig(project.Language)
+	if err != nil {
+		return nil, err
+	}
+	df, err := dockerfile.ParseFile(filepath.Join(outputPath, "Dockerfile"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	// Workspace packages are built from the workspace root
+	contextDir, pkg := outputPath, ""
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		contextDir, pkg = filepath.Join(outputPath, workspace.Root), workspace.Package
+	}
+	sources := copySources(df, contextDir)
+
+	ignorePath := filepath.Join(contextDir, ".dockerignore")
+	content := ""
+	if data, err := ioutil.ReadFile(ignorePath); err == nil {
+		content = string(data)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	// .gitignore entries the Dockerfile depends on, such as a go.work file
+	// kept out of git, must stay in the build context
+	var gitignore []string
+	for _, pattern := range gitignorePatterns(contextDir, "") {
+		gitignore = appendNeeded(gitignore, pattern, sources)
+	}
+	if pkg != "" {
+		for _, pattern := range gitignorePatterns(filepath.Join(contextDir, pkg), pkg) {
+			gitignore = appendNeeded(gitignore, pattern, sources)
+		}
+	}
+
+	sections := []struct {
+		comment  string
+		patterns []string
+	}{
+		{"Version control, editor settings, local environment and Docker files", commonIgnores},
+		{language.Name + " dependencies and build output, rebuilt in the image", language.Dockerignore},
+		{"From .gitignore", gitignore},
+	}
+	updated := content
+	for _, section := range sections {
+		var missing []string
+		for _, pattern := range section.patterns {
+			if !existing[pattern] {
+				missing = append(missing, pattern)
+				existing[pattern] = true
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if updated != "" {
+			updated = strings.TrimRight(updated, "\n") + "\n\n"
+		}
+		updated += "# " + section.comment + "\n" + strings.Join(missing, "\n") + "\n"
+	}
+	if updated != content {
+		if err := os.WriteFile(ignorePath, []byte(updated), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write .dockerignore: %w", err)
+		}
+	}
+
+	rules := parseIgnoreRules(strings.Split(updated, "\n"))
+	var warnings []string
+	for _, source := range sources {
+		if ignored(rules, source) {
+			warnings = append(warnings, fmt.Sprintf("the Dockerfile copies %s, but .dockerignore excludes it", source))
+		}
+	}
+	filepath.WalkDir(contextDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || file == contextDir {
+			return nil
+		}
+		rel, _ := filepath.Rel(contextDir, file)
+		rel = filepath.ToSlash(rel)
+		if ignored(rules, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && secretFileRe.MatchString(entry.Name()) && !secretExamples.MatchString(entry.Name()) && copied(df, rel) {
+			warnings = append(warnings, fmt.Sprintf("%s looks like a credential and would be copied into the image, add it to .dockerignore", rel))
+		}
+		return nil
+	})
+	return warnings, nil
+}
+
+// copySources returns the files in the build context that COPY and ADD
+// instructions name, with wildcards expanded. Whole directory copies such as
+// COPY . . are left out.
+func copySources(df *dockerfile.Dockerfile, contextDir string) []string {
+	var sources []string
+	for _, stage := range df.Stages {
+		for _, instruction := range stage.Instructions {
+			for _, source := range contextSources(instruction) {
+				if path.Clean(source) == "." {
+					continue
+				}
+				matches, _ := filepath.Glob(filepath.Join(contextDir, source))
+				for _, match := range matches {
+					if rel, err := filepath.Rel(contextDir, match); err == nil {
+						sources = append(sources, filepath.ToSlash(rel))
+					}
+				}
+			}
+		}
+	}
+	return sources
+}
+
+// contextSources returns the build context paths of a COPY or ADD
+func contextSources(instruction *dockerfile.Instruction) []string {
+	if (instruction.Keyword != "COPY" && instruction.Keyword != "ADD") || instruction.Flag("from") != "" {
+		return nil
+	}
+	args, ok := instruction.ExecArgs()
+	if !ok {
+		args = instruction.Fields()
+	}
+	if len(args) < 2 {
+		return nil
+	}
+	return args[:len(args)-1]
+}
+
+// copied reports whether a COPY or ADD puts the file into the image
+func copied(df *dockerfile.Dockerfile, file string) bool {
+	for _, stage := range df.Stages {
+		for _, instruction := range stage.Instructions {
+			for _, source := range contextSources(instruction) {
+				source = path.Clean(source)
+				if source == "." || file == source || strings.HasPrefix(file, source+"/") {
+					return true
+				}
+				if matched, _ := path.Match(source, file); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// gitignorePatterns reads a .gitignore and converts its patterns to
+// .dockerignore syntax, relative to the build context. Git matches
+// unanchored patterns in every directory, .dockerignore only at the root.
+func gitignorePatterns(dir, prefix string) []string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		pattern := strings.TrimPrefix(line, "!")
+		anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if !anchored && !strings.HasPrefix(pattern, "**/") {
+			pattern = "**/" + pattern
+		}
+		if prefix != "" {
+			pattern = prefix + "/" + pattern
+		}
+		if negate {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// appendNeeded adds a pattern unless it excludes one of the sources
+func appendNeeded(patterns []string, pattern string, sources []string) []string {
+	rules := parseIgnoreRules([]string{pattern})
+	for _, source := range sources {
+		if ignored(rules, source) {
+			return patterns
+		}
+	}
+	return append(patterns, pattern)
+}
+
+// parseIgnoreRules compiles .dockerignore lines
+func parseIgnoreRules(lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		pattern := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(line, "!")), "/")
+		if re := ignoreRegexp(pattern); re != nil {
+			rules = append(rules, ignoreRule{re: re, negate: negate})
+		}
+	}
+	return rules
+}
+
+// ignoreRegexp compiles a pattern. It matches the path itself and, like
+// Docker, everything below a matching directory.
+func ignoreRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[' && strings.IndexByte(pattern[i:], ']') > 1:
+			end := i + strings.IndexByte(pattern[i:], ']')
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("(/.*)?$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// ignored reports whether the last matching rule excludes the path
+func ignored(rules []ignoreRule, file string) bool {
+	excluded := false
+	for _, rule := range rules {
+		if rule.re.MatchString(file) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// updateDockerfile keeps a hand-written Dockerfile and only syncs the
+// exposed ports of its final stage with the project configuration. The file
+// is left as written when it exposes those ports already.
+func updateDockerfile(project *analyzer.ProjectType) error {
+	path := project.ExistingDockerfile.Path
+	df, err := dockerfile.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	final := df.FinalStage()
+	if final == nil || len(project.Ports) == 0 {
+		return nil
+	}
+
+	exposes := final.Find("EXPOSE")
+	var exposed []string
+	for _, expose := range exposes {
+		for _, port := range expose.Fields() {
+			exposed = append(exposed, strings.Split(port, "/")[0])
+		}
+	}
+	if strings.Join(exposed, " ") == strings.Join(project.Ports, " ") {
+		return nil
+	}
+
+	// Replace the first EXPOSE and drop the others
+	for _, expose := range exposes[min(1, len(exposes)):] {
+		final.Remove(expose)
+	}
+	if len(exposes) > 0 {
+		exposes[0].Args = strings.Join(project.Ports, " ")
+	} else {
+		// Ports are declared right before the command
+		expose := &dockerfile.Instruction{Keyword: "EXPOSE", Args: strings.Join(project.Ports, " ")}
+		at := final.Index("CMD")
+		if entrypoint := final.Index("ENTRYPOINT"); entrypoint != -1 && (at == -1 || entrypoint < at) {
+			at = entrypoint
+		}
+		final.Insert(at, expose)
+	}
+
+	return os.WriteFile(path, []byte(dockerfile.Format(df)), 0644)
+}
+
+// mergeCompose adds the generated services, volumes and networks that are
+// missing from an existing compose file. Everything already in the file is
+// kept untouched, including comments and ordering.
+func mergeCompose(compose *ComposeConfig, existingCompose *analyzer.ExistingCompose) error {
+	path := existingCompose.Path
+
+	// Services the file already provides under another name are not added again
+	for name := range compose.Services {
+		if existingCompose.Provides(name, compose.Services[name].Image) {
+			delete(compose.Services, name)
+			delete(compose.Volumes, name+"-data")
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var existing yaml.Node
+	if err := yaml.Unmarshal(data, &existing); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(existing.Content) == 0 || existing.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a compose file", path)
+	}
+
+	var generated yaml.Node
+	if err := generated.Encode(compose); err != nil {
+		return err
+	}
+
+	root := existing.Content[0]
+	for _, section := range []string{"services", "networks", "volumes", "secrets"} {
+		source := mappingValue(&generated, section)
+		if source == nil || len(source.Content) == 0 {
+			continue
+		}
+		// Networks, volumes and secrets are only needed by services we add
+		if section != "services" && len(compose.Services) == 0 {
+			continue
+		}
+
+		target := mappingValue(root, section)
+		if target == nil {
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: section},
+				&yaml.Node{Kind: yaml.MappingNode})
+			target = root.Content[len(root.Content)-1]
+		}
+
+		for i := 0; i+1 < len(source.Content); i += 2 {
+			if mappingValue(target, source.Content[i].Value) == nil {
+				target.Content = append(target.Content, source.Content[i], source.Content[i+1])
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&existing); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
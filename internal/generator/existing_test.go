@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/analyzer"
+)
+
+func TestUpdateDockerfileKeepsText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Dockerfile")
+	original := "from node:18-alpine\n# the app\ncopy . .\nexpose 3000/tcp\nCMD node index.js\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project := &analyzer.ProjectType{
+		ExistingDockerfile: &analyzer.ExistingDockerfile{Path: path},
+		Ports:              []string{"3000"},
+	}
+
+	if err := updateDockerfile(project); err != nil {
+		t.Fatalf("updateDockerfile: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("Dockerfile rewritten although its ports match:\n%s", got)
+	}
+
+	project.Ports = []string{"8080"}
+	if err := updateDockerfile(project); err != nil {
+		t.Fatalf("updateDockerfile: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !strings.Contains(string(got), "EXPOSE 8080\n") {
+		t.Errorf("Dockerfile does not expose the new port:\n%s", got)
+	}
+}
+
+func TestComposeFilePathKeepsExistingName(t *testing.T) {
+	dir := t.TempDir()
+	project := &analyzer.ProjectType{}
+	if got := composeFilePath(project, dir); got != filepath.Join(dir, "docker-compose.yml") {
+		t.Errorf("new compose file %s, want docker-compose.yml", got)
+	}
+
+	// Regenerating replaces compose.yaml, docker compose would not read a
+	// docker-compose.yml next to it
+	project.ExistingCompose = &analyzer.ExistingCompose{Path: filepath.Join(dir, "compose.yaml")}
+	for _, inPlace := range []bool{true, false} {
+		project.UpdateInPlace = inPlace
+		if got := composeFilePath(project, dir); got != project.ExistingCompose.Path {
+			t.Errorf("update in place %v: compose file %s, want compose.yaml", inPlace, got)
+		}
+		if got := ComposeOverridePath(project, dir); got != filepath.Join(dir, "compose.override.yaml") {
+			t.Errorf("update in place %v: override file %s, want compose.override.yaml", inPlace, got)
+		}
+	}
+}
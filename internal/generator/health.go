@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// healthCheckFlags are the HEALTHCHECK timings, mirrored in compose
+var healthCheckFlags = []string{"--interval=30s", "--timeout=5s", "--start-period=30s", "--retries=3"}
+
+// healthScripts probe the application with the language's own interpreter,
+// so images without curl or wget can be checked. %[1]s is the URL and %[2]s
+// the port.
+var healthScripts = map[string]struct {
+	interpreter string
+	flag        string
+	http        string
+	tcp         string
+}{
+	"Node.js": {"node", "-e",
+		"fetch('%[1]s').then(r => process.exit(r.ok ? 0 : 1), () => process.exit(1))",
+		"require('net').connect(%[2]s, '127.0.0.1').on('connect', () => process.exit(0)).on('error', () => process.exit(1))"},
+	"Bun": {"bun", "-e",
+		"fetch('%[1]s').then(r => process.exit(r.ok ? 0 : 1), () => process.exit(1))",
+		"require('net').connect(%[2]s, '127.0.0.1').on('connect', () => process.exit(0)).on('error', () => process.exit(1))"},
+	"Deno": {"deno", "eval",
+		"fetch('%[1]s').then(r => Deno.exit(r.ok ? 0 : 1), () => Deno.exit(1))",
+		"Deno.connect({ hostname: '127.0.0.1', port: %[2]s }).then(() => Deno.exit(0), () => Deno.exit(1))"},
+	"Python": {"python", "-c",
+		"import urllib.request; urllib.request.urlopen('%[1]s', timeout=4)",
+		"import socket; socket.create_connection(('127.0.0.1', %[2]s), timeout=4)"},
+	// php-fpm speaks FastCGI, not HTTP
+	"PHP": {"php", "-r", "",
+		"exit(@fsockopen('127.0.0.1', %[2]s) ? 0 : 1);"},
+}
+
+// healthProbe returns the command that checks the application and what it
+// checks, using only what the final image ships: the language's interpreter,
+// busybox wget and nc on Alpine, or bash on Debian. The command is nil when
+// nothing can probe the application.
+func healthProbe(project *analyzer.ProjectType, stage *dockerfile.Stage, flavor *analyzer.RuntimeFlavor) ([]string, string) {
+	if len(project.Ports) == 0 {
+		return nil, ""
+	}
+	port := project.Ports[0]
+	url := "http://127.0.0.1:" + port + project.HealthCheck
+	responds := "Healthy once " + project.HealthCheck + " responds"
+	accepts := "Healthy once the application accepts connections on port " + port
+
+	// Elixir releases report whether the VM is up
+	if project.Language == "Elixir" && project.AppName != "" {
+		return []string{"/app/bin/" + project.AppName, "pid"}, "Healthy once the release is running"
+	}
+
+	if script, ok := healthScripts[project.Language]; ok && !project.StaticSite {
+		interpreter := script.interpreter
+		if flavor != nil && flavor.Interpreter != "" {
+			interpreter = flavor.Interpreter
+		}
+		if project.HealthCheck != "" && script.http != "" {
+			return []string{interpreter, script.flag, fmt.Sprintf(script.http, url, port)}, responds
+		}
+		return []string{interpreter, script.flag, fmt.Sprintf(script.tcp, url, port)}, accepts
+	}
+
+	shell := flavor == nil || flavor.Shell
+	switch {
+	case !shell:
+		return nil, ""
+	case strings.Contains(stage.Image, "alpine"):
+		if project.HealthCheck != "" {
+			return []string{"wget", "-q", "--spider", url}, responds
+		}
+		return []string{"nc", "-z", "127.0.0.1", port}, accepts
+	default:
+		return []string{"bash", "-c", "</dev/tcp/127.0.0.1/" + port}, accepts
+	}
+}
+
+// addHealthCheck adds a HEALTHCHECK to the final stage unless the template
+// already has one
+func addHealthCheck(df *dockerfile.Dockerfile, project *analyzer.ProjectType, flavor *analyzer.RuntimeFlavor) {
+	stage := df.FinalStage()
+	if stage == nil || len(stage.Find("HEALTHCHECK")) > 0 {
+		return
+	}
+	probe, comment := healthProbe(project, stage, flavor)
+	if probe == nil {
+		return
+	}
+	stage.Insert(runIndex(stage), &dockerfile.Instruction{
+		Comments: []string{comment},
+		Keyword:  "HEALTHCHECK",
+		Flags:    append([]string(nil), healthCheckFlags...),
+		Args:     "CMD " + dockerfile.ExecForm(probe...),
+	})
+}
+
+// composeHealthCheck mirrors the final stage's HEALTHCHECK in compose, so
+// depends_on conditions and `docker compose ps` see the same check
+func composeHealthCheck(df *dockerfile.Dockerfile) *HealthCheck {
+	stage := df.FinalStage()
+	if stage == nil {
+		return nil
+	}
+	checks := stage.Find("HEALTHCHECK")
+	if len(checks) == 0 {
+		return nil
+	}
+	check := checks[len(checks)-1]
+	command, ok := strings.CutPrefix(strings.TrimSpace(check.Args), "CMD")
+	if !ok {
+		// HEALTHCHECK NONE
+		return nil
+	}
+
+	healthCheck := &HealthCheck{
+		Interval:    check.Flag("interval"),
+		Timeout:     check.Flag("timeout"),
+		StartPeriod: check.Flag("start-period"),
+		Retries:     3,
+	}
+	fmt.Sscan(check.Flag("retries"), &healthCheck.Retries)
+
+	probe := &dockerfile.Instruction{Args: strings.TrimSpace(command)}
+	if args, ok := probe.ExecArgs(); ok {
+		healthCheck.Test = append([]string{"CMD"}, args...)
+	} else {
+		healthCheck.Test = []string{"CMD-SHELL", probe.Args}
+	}
+	return healthCheck
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// labelPrefix namespaces the OCI image annotations
+const labelPrefix = "org.opencontainers.image."
+
+// labelArgs are filled in at build time, e.g. by CI with the commit and
+// build timestamp. Compose passes them through from the environment.
+var labelArgs = []string{"REVISION", "CREATED"}
+
+// addLabels labels the final stage with the project's OCI metadata. The
+// instructions come right before the command so the changing revision and
+// timestamp do not invalidate the cached layers.
+func addLabels(df *dockerfile.Dockerfile, project *analyzer.ProjectType) {
+	stage := df.FinalStage()
+	metadata := project.Metadata
+	if stage == nil || metadata == nil {
+		return
+	}
+
+	var labels []string
+	for _, label := range []struct{ key, value string }{
+		{"title", metadata.Title},
+		{"description", metadata.Description},
+		{"source", metadata.Source},
+		{"licenses", metadata.Licenses},
+	} {
+		if label.value != "" {
+			labels = append(labels, labelPrefix+label.key+"="+quoteLabel(label.value))
+		}
+	}
+	labels = append(labels,
+		labelPrefix+`version="$VERSION"`,
+		labelPrefix+`revision="$REVISION"`,
+		labelPrefix+`created="$CREATED"`,
+	)
+
+	index := runIndex(stage)
+	version := &dockerfile.Instruction{
+		Comments: []string{"Image metadata, pass REVISION and CREATED as build args"},
+		Keyword:  "ARG",
+		Args:     "VERSION",
+	}
+	if metadata.Version != "" {
+		version.Args += "=" + metadata.Version
+	}
+	stage.Insert(index, version)
+	index++
+	for _, arg := range labelArgs {
+		stage.Insert(index, &dockerfile.Instruction{Keyword: "ARG", Args: arg})
+		index++
+	}
+	stage.Insert(index, &dockerfile.Instruction{
+		Keyword: "LABEL",
+		Args:    strings.Join(labels, " \\\n      "),
+	})
+}
+
+// quoteLabel quotes a label value, escaping what the Dockerfile would
+// otherwise expand or end the string with
+func quoteLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", " ").Replace(value)
+	return `"` + value + `"`
+}
+
+// composeBuildArgs passes the label build args through from the
+// environment, empty when unset
+func composeBuildArgs() map[string]string {
+	args := make(map[string]string)
+	for _, arg := range labelArgs {
+		args[arg] = "${" + arg + ":-}"
+	}
+	return args
+}
+
+// hasLabelArgs reports whether the final stage declares the label build
+// args, which hand-written Dockerfiles usually do not
+func hasLabelArgs(df *dockerfile.Dockerfile) bool {
+	stage := df.FinalStage()
+	if stage == nil {
+		return false
+	}
+	for _, arg := range stage.Find("ARG") {
+		if name, _, _ := strings.Cut(arg.Args, "="); name == labelArgs[0] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dockerizer-cli/internal/dockerfile"
+	"dockerizer-cli/internal/registry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile records the digests the project's images are pinned to
+const LockFile = "dockerizer.lock"
+
+// lockedComposeFiles are the compose files whose images are locked
+var lockedComposeFiles = []string{
+	"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml",
+	"docker-compose.override.yml", "docker-compose.override.yaml", "compose.override.yml", "compose.override.yaml",
+	"compose.test.yml",
+}
+
+// composeImageRe matches the image of a compose service, keeping the
+// indentation, key and quotes around the reference
+var composeImageRe = regexp.MustCompile(`(?m)^(\s*image:\s*["']?)([^"'\s#]+)`)
+
+// dockerfileImageRes match the base images of FROM lines and the images
+// COPY --from copies from, keeping what comes before the reference
+var dockerfileImageRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?im)^(\s*FROM\s+(?:--\S+\s+)*)([^\s#]+)`),
+	regexp.MustCompile(`(?im)^(\s*COPY\s+(?:--\S+\s+)*--from=)([^\s#]+)`),
+}
+
+// Lock maps image references, as written in the generated files, to the
+// digests they are pinned to
+type Lock struct {
+	Images map[string]string `yaml:"images"`
+}
+
+// readLock reads the project's lock file, nil when there is none
+func readLock(dir string) (*Lock, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, LockFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock Lock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+	return &lock, nil
+}
+
+// pin returns the reference with its locked digest, or unchanged when the
+// image is not locked
+func (l *Lock) pin(ref string) string {
+	if l == nil {
+		return ref
+	}
+	name, _, _ := strings.Cut(ref, "@")
+	if digest, ok := l.Images[name]; ok {
+		return name + "@" + digest
+	}
+	return ref
+}
+
+// pinDockerfile pins the base images of the stages and the images files
+// are copied from
+func pinDockerfile(df *dockerfile.Dockerfile, lock *Lock) {
+	forEachImage(df, func(image string) string { return lock.pin(image) })
+}
+
+// pinCompose pins the images of the compose services
+func pinCompose(compose *ComposeConfig, lock *Lock) {
+	for name, service := range compose.Services {
+		if service.Image != "" {
+			service.Image = lock.pin(service.Image)
+			compose.Services[name] = service
+		}
+	}
+}
+
+// pinReferences pins the references re matches as its second group,
+// leaving the rest of the text as it is
+func pinReferences(content string, re *regexp.Regexp, lock *Lock) string {
+	return re.ReplaceAllStringFunc(content, func(text string) string {
+		match := re.FindStringSubmatch(text)
+		return match[1] + lock.pin(match[2])
+	})
+}
+
+// forEachImage replaces every image reference of a Dockerfile with what
+// replace returns for it. Stage names, scratch and references built from
+// build args are not images that can be pinned.
+func forEachImage(df *dockerfile.Dockerfile, replace func(image string) string) {
+	stages := make(map[string]bool)
+	isImage := func(ref string) bool {
+		return ref != "" && ref != "scratch" && !stages[strings.ToLower(ref)] && !strings.Contains(ref, "$")
+	}
+	for _, stage := range df.Stages {
+		if isImage(stage.Image) {
+			stage.Image = replace(stage.Image)
+		}
+		for _, instruction := range stage.Instructions {
+			if instruction.Keyword != "COPY" {
+				continue
+			}
+			for j, flag := range instruction.Flags {
+				from, ok := strings.CutPrefix(flag, "--from=")
+				if !ok || !isImage(from) || strings.Trim(from, "0123456789") == "" {
+					continue
+				}
+				instruction.Flags[j] = "--from=" + replace(from)
+			}
+		}
+		if stage.Name != "" {
+			stages[strings.ToLower(stage.Name)] = true
+		}
+	}
+}
+
+// UpdateLock resolves the current digest of every image the project's
+// Dockerfile and compose files use, writes them to the lock file and pins
+// the files to them. It returns the locked references.
+func UpdateLock(dir string, client *registry.Client) ([]string, error) {
+	var df *dockerfile.Dockerfile
+	var dockerfileContent string
+	dockerfilePath := filepath.Join(dir, "Dockerfile")
+	if data, err := ioutil.ReadFile(dockerfilePath); err == nil {
+		if df, err = dockerfile.Parse(strings.NewReader(string(data))); err != nil {
+			return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		dockerfileContent = string(data)
+	}
+	composeFiles := make(map[string]string)
+	for _, name := range lockedComposeFiles {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, name)); err == nil {
+			composeFiles[name] = string(data)
+		}
+	}
+	if df == nil && len(composeFiles) == 0 {
+		return nil, fmt.Errorf("no Dockerfile or compose file in %s", dir)
+	}
+
+	// The references without their digests are what gets locked
+	lock := &Lock{Images: make(map[string]string)}
+	var refs []string
+	collect := func(ref string) string {
+		name, _, _ := strings.Cut(ref, "@")
+		if _, ok := lock.Images[name]; !ok {
+			lock.Images[name] = ""
+			refs = append(refs, name)
+		}
+		return ref
+	}
+	if df != nil {
+		forEachImage(df, collect)
+	}
+	for _, content := range composeFiles {
+		for _, match := range composeImageRe.FindAllStringSubmatch(content, -1) {
+			if !strings.Contains(match[2], "$") {
+				collect(match[2])
+			}
+		}
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		digest, err := client.Digest(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		lock.Images[ref] = digest
+	}
+
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+	header := "# Image digests the Docker files are pinned to, refresh them with\n# `dockerizer lock update`\n"
+	if err := os.WriteFile(filepath.Join(dir, LockFile), append([]byte(header), data...), 0644); err != nil {
+		return nil, err
+	}
+
+	// The files are edited in place to keep their formatting and comments
+	if df != nil {
+		pinned := dockerfileContent
+		for _, re := range dockerfileImageRes {
+			pinned = pinReferences(pinned, re, lock)
+		}
+		if pinned != dockerfileContent {
+			if err := os.WriteFile(dockerfilePath, []byte(pinned), 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name, content := range composeFiles {
+		if pinned := pinReferences(content, composeImageRe, lock); pinned != content {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(pinned), 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return refs, nil
+}
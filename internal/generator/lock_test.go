@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/registry"
+)
+
+func TestUpdateLockEditsInPlace(t *testing.T) {
+	digests := map[string]string{
+		"/v2/library/golang/manifests/1.21-alpine": "sha256:aaa",
+		"/v2/library/alpine/manifests/3.19":        "sha256:bbb",
+		"/v2/library/postgres/manifests/15-alpine": "sha256:ccc",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digest, ok := digests[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	dockerfile := `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.21
+from golang:1.21-alpine as builder
+WORKDIR /src
+RUN apk add --no-cache git && \
+    # certificates for go mod download
+    apk add --no-cache ca-certificates && \
+
+    true
+COPY . .
+RUN go build -o /app .
+
+FROM   alpine:3.19   AS runtime
+COPY --from=builder /app /app
+COPY --chown=1000 --from=alpine:3.19 /etc/ssl/certs /etc/ssl/certs
+CMD ["/app"]
+`
+	compose := `services:
+  app:
+    build: .
+  db:
+    # the database
+    image: "postgres:15-alpine"
+`
+	for name, content := range map[string]string{"Dockerfile": dockerfile, "docker-compose.yml": compose} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	refs, err := UpdateLock(dir, &registry.Client{Mirror: server.URL})
+	if err != nil {
+		t.Fatalf("UpdateLock: %v", err)
+	}
+	if got := strings.Join(refs, " "); got != "alpine:3.19 golang:1.21-alpine postgres:15-alpine" {
+		t.Errorf("locked %s", got)
+	}
+
+	wantDockerfile := strings.NewReplacer(
+		"golang:1.21-alpine as", "golang:1.21-alpine@sha256:aaa as",
+		"alpine:3.19 ", "alpine:3.19@sha256:bbb ",
+	).Replace(dockerfile)
+	if got, _ := os.ReadFile(filepath.Join(dir, "Dockerfile")); string(got) != wantDockerfile {
+		t.Errorf("Dockerfile:\n%s\nwant:\n%s", got, wantDockerfile)
+	}
+	wantCompose := strings.Replace(compose, "postgres:15-alpine", "postgres:15-alpine@sha256:ccc", 1)
+	if got, _ := os.ReadFile(filepath.Join(dir, "docker-compose.yml")); string(got) != wantCompose {
+		t.Errorf("docker-compose.yml:\n%s\nwant:\n%s", got, wantCompose)
+	}
+
+	lock, err := readLock(dir)
+	if err != nil || lock.Images["alpine:3.19"] != "sha256:bbb" {
+		t.Errorf("lock file %+v, %v", lock, err)
+	}
+
+	// Updating again replaces the pinned digests instead of adding to them
+	digests["/v2/library/alpine/manifests/3.19"] = "sha256:ddd"
+	if _, err := UpdateLock(dir, &registry.Client{Mirror: server.URL}); err != nil {
+		t.Fatalf("UpdateLock: %v", err)
+	}
+	wantDockerfile = strings.ReplaceAll(wantDockerfile, "sha256:bbb", "sha256:ddd")
+	if got, _ := os.ReadFile(filepath.Join(dir, "Dockerfile")); string(got) != wantDockerfile {
+		t.Errorf("Dockerfile after a second update:\n%s\nwant:\n%s", got, wantDockerfile)
+	}
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// TestMain runs the tests from the repository root, where the catalog is
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// generate analyzes a project made of files and returns its Dockerfile
+func generate(t *testing.T, files map[string]string) *dockerfile.Dockerfile {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	project, err := analyzer.AnalyzeProject(dir)
+	if err != nil {
+		t.Fatalf("AnalyzeProject: %v", err)
+	}
+	analyzer.DetectNativeModules(dir, project)
+	analyzer.DetectTestCommand(dir, project)
+	analyzer.DetectAppServer(dir, project)
+	if err := GenerateDockerfile(project, dir); err != nil {
+		t.Fatalf("GenerateDockerfile: %v", err)
+	}
+
+	df, err := dockerfile.ParseFile(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	return df
+}
+
+// writeFiles creates files, given by their path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
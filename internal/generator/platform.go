@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// addNativeBuild installs the toolchain and headers for the project's
+// native dependencies in the build stage, and the libraries they load in the
+// final stage. The build stage runs on the target platform, so modules
+// without a prebuilt binary for it are compiled there.
+func addNativeBuild(df *dockerfile.Dockerfile, project *analyzer.ProjectType, language *analyzer.LanguageConfig, flavor *analyzer.RuntimeFlavor) error {
+	builder := buildStage(df)
+	if len(project.NativeModules) == 0 || builder == nil {
+		return nil
+	}
+
+	build := append([]string(nil), language.NativeBuild.Packages...)
+	var runtime []string
+	for _, module := range project.NativeModules {
+		dep := language.NativeBuild.Dependencies[module]
+		build = append(build, dep.Build...)
+		runtime = append(runtime, dep.Runtime...)
+	}
+
+	// The dev stage installs the same dependencies
+	for _, stage := range []*dockerfile.Stage{builder, df.Stage(devStage)} {
+		if stage == nil {
+			continue
+		}
+		stage.Insert(firstCopy(stage), &dockerfile.Instruction{
+			Comments: []string{fmt.Sprintf("Toolchain for native modules (%s) without a prebuilt binary for the platform", strings.Join(project.NativeModules, ", "))},
+			Keyword:  "RUN",
+			Args:     installCommand(stage.Image, build),
+		})
+	}
+
+	if len(runtime) == 0 {
+		return nil
+	}
+	if flavor != nil && !flavor.Shell {
+		return fmt.Errorf("the native modules %s need %s, which the %s runtime cannot install; use another runtime flavor",
+			strings.Join(project.NativeModules, ", "), strings.Join(runtime, ", "), flavor.Name)
+	}
+	final := df.FinalStage()
+	final.Insert(firstCopy(final), &dockerfile.Instruction{
+		Comments: []string{"Shared libraries the native modules load"},
+		Keyword:  "RUN",
+		Args:     installCommand(final.Image, runtime),
+	})
+	return nil
+}
+
+// buildStage returns the stage the production stage is built from: the one
+// it copies files from, or else the last stage before it that is not the dev
+// or test stage. It is nil for single-stage Dockerfiles.
+func buildStage(df *dockerfile.Dockerfile) *dockerfile.Stage {
+	final := df.FinalStage()
+	if final == nil {
+		return nil
+	}
+	for _, instruction := range final.Find("COPY") {
+		if from := instruction.Flag("from"); from != "" {
+			if stage := df.Stage(from); stage != nil && stage != final {
+				return stage
+			}
+		}
+	}
+	for i := len(df.Stages) - 2; i >= 0; i-- {
+		if name := df.Stages[i].Name; !strings.EqualFold(name, devStage) && !strings.EqualFold(name, testStage) {
+			return df.Stages[i]
+		}
+	}
+	return nil
+}
+
+// firstCopy returns the index of the stage's first COPY, or the end of the
+// stage
+func firstCopy(stage *dockerfile.Stage) int {
+	if index := stage.Index("COPY"); index != -1 {
+		return index
+	}
+	return len(stage.Instructions)
+}
+
+// dropBuildPlatform runs every stage on the target platform
+func dropBuildPlatform(df *dockerfile.Dockerfile) {
+	for _, stage := range df.Stages {
+		var flags []string
+		for _, flag := range stage.Flags {
+			if flag != "--platform=$BUILDPLATFORM" {
+				flags = append(flags, flag)
+			}
+		}
+		stage.Flags = flags
+	}
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// toolchain returns the RUN instructions of a stage installing packages
+func toolchain(stage *dockerfile.Stage, pkg string) []string {
+	var runs []string
+	for _, run := range stage.Find("RUN") {
+		if strings.Contains(run.Args, pkg) {
+			runs = append(runs, run.Args)
+		}
+	}
+	return runs
+}
+
+func TestNativeBuildWithTestStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pkg     string
+		install string
+	}{
+		{
+			name: "node bcrypt",
+			files: map[string]string{
+				"package.json": `{"name": "app", "scripts": {"start": "node index.js", "test": "jest"},
+					"dependencies": {"express": "^4.18.0", "bcrypt": "^5.1.0"}}`,
+				"index.js": "require('express')().listen(3000)\n",
+			},
+			pkg:     "g++",
+			install: "apk add",
+		},
+		{
+			name: "python psycopg2",
+			files: map[string]string{
+				"requirements.txt": "flask==3.0.0\npsycopg2==2.9.9\n",
+				"app.py":           "from flask import Flask\napp = Flask(__name__)\n",
+				"pytest.ini":       "[pytest]\n",
+			},
+			pkg:     "libpq-dev",
+			install: "apt-get install",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := generate(t, tt.files)
+			if df.Stage(testStage) == nil {
+				t.Fatalf("no %s stage:\n%s", testStage, df)
+			}
+
+			builder := df.Stage("builder")
+			if runs := toolchain(builder, tt.pkg); len(runs) != 1 || !strings.Contains(runs[0], tt.install) {
+				t.Errorf("builder stage installs %q, want one %s of %s:\n%s", runs, tt.install, tt.pkg, df)
+			}
+			if runs := toolchain(df.Stage(devStage), tt.pkg); len(runs) != 1 {
+				t.Errorf("dev stage installs %q, want the toolchain once", runs)
+			}
+			test := df.Stage(testStage)
+			if runs := toolchain(test, tt.pkg); len(runs) != 0 {
+				t.Errorf("test stage installs %q, it inherits the dev stage's toolchain", runs)
+			}
+			if last := test.Instructions[len(test.Instructions)-1]; last.Keyword != "CMD" {
+				t.Errorf("test stage ends with %s, want CMD", last)
+			}
+			if got := buildStage(df); got != builder {
+				t.Errorf("buildStage returned %q, want builder", got.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// applyRuntimeFlavor adds what the flavor's image lacks to the final stage:
+// packages such as CA certificates and timezone data, files copied from the
+// build stage and environment. The command is reduced to the arguments of
+// the image's entrypoint.
+func applyRuntimeFlavor(df *dockerfile.Dockerfile, flavor *analyzer.RuntimeFlavor) error {
+	stage := df.FinalStage()
+	if flavor == nil || stage == nil {
+		return nil
+	}
+
+	var setup []*dockerfile.Instruction
+	if len(flavor.Packages) > 0 {
+		setup = append(setup, &dockerfile.Instruction{Keyword: "RUN", Args: installCommand(stage.Image, flavor.Packages)})
+	}
+	if builder := buildStage(df); len(flavor.Copy) > 0 && builder != nil {
+		for _, file := range flavor.Copy {
+			dest := path.Dir(file) + "/"
+			setup = append(setup, &dockerfile.Instruction{Keyword: "COPY", Flags: []string{"--from=" + builder.Name}, Args: file + " " + dest})
+		}
+	}
+	for _, env := range flavor.Env {
+		setup = append(setup, &dockerfile.Instruction{Keyword: "ENV", Args: env})
+	}
+	if len(setup) > 0 {
+		setup[0].Comment(fmt.Sprintf("Certificates, timezone data and settings the %s image needs", flavor.Name))
+		stage.Instructions = append(setup, stage.Instructions...)
+	}
+
+	if len(flavor.Entrypoint) > 0 {
+		for _, instruction := range stage.Find("CMD") {
+			args, err := entrypointArgs(instruction, flavor)
+			if err != nil {
+				return err
+			}
+			instruction.Args = args
+		}
+	}
+	return nil
+}
+
+// installCommand installs packages with the package manager of the image's
+// distribution
+func installCommand(image string, packages []string) string {
+	if strings.Contains(image, "alpine") {
+		return "apk add --no-cache " + strings.Join(packages, " ")
+	}
+	return "apt-get update && apt-get install -y --no-install-recommends " + strings.Join(packages, " ") +
+		" \\\n    && rm -rf /var/lib/apt/lists/*"
+}
+
+// entrypointArgs turns a CMD into the arguments of an interpreter entrypoint:
+// the interpreter itself is dropped, other commands run as modules when the
+// interpreter supports it, and scripts are passed as they are
+func entrypointArgs(cmd *dockerfile.Instruction, flavor *analyzer.RuntimeFlavor) (string, error) {
+	args, ok := cmd.ExecArgs()
+	if !ok || len(args) == 0 {
+		return "", fmt.Errorf("the %s runtime needs CMD in exec form, not %s", flavor.Name, cmd)
+	}
+	for _, interpreter := range flavor.Entrypoint {
+		if args[0] == interpreter {
+			return dockerfile.ExecForm(args[1:]...), nil
+		}
+	}
+	switch {
+	case flavor.ModuleFlag != "":
+		return dockerfile.ExecForm(append([]string{flavor.ModuleFlag}, args...)...), nil
+	case strings.Contains(args[0], "/") || path.Ext(args[0]) != "":
+		return cmd.Args, nil
+	}
+	return "", fmt.Errorf("the %s runtime only runs %s, set a start_command that does not use %s",
+		flavor.Name, strings.Join(flavor.Entrypoint, " or "), args[0])
+}
+
+// checkShell reports shell instructions left in a final stage whose image
+// has no shell
+func checkShell(df *dockerfile.Dockerfile, flavor *analyzer.RuntimeFlavor) error {
+	stage := df.FinalStage()
+	if flavor == nil || flavor.Shell || stage == nil {
+		return nil
+	}
+	for _, instruction := range stage.Instructions {
+		switch instruction.Keyword {
+		case "RUN":
+			return fmt.Errorf("the %s runtime has no shell for %s", flavor.Name, instruction)
+		case "CMD", "ENTRYPOINT":
+			if _, ok := instruction.ExecArgs(); !ok {
+				return fmt.Errorf("the %s runtime has no shell for %s, use the exec form", flavor.Name, instruction)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+)
+
+// ComposeSecret represents a top-level secret in docker-compose.yml
+type ComposeSecret struct {
+	File        string `yaml:"file,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+}
+
+// secretRun prefixes a RUN command with the secret mounts it needs. Secrets
+// read into variables only exist for the duration of the command.
+func secretRun(secrets []analyzer.BuildSecret, command string) string {
+	var mounts, variables []string
+	for _, secret := range secrets {
+		mount := "--mount=type=secret,id=" + secret.ID
+		if secret.Target != "" {
+			mount += ",target=" + secret.Target
+		}
+		mounts = append(mounts, mount)
+		if secret.Variable != "" {
+			variables = append(variables, fmt.Sprintf(`%s="$(cat /run/secrets/%s)"`, secret.Variable, secret.ID))
+		}
+	}
+	return strings.TrimSpace(strings.Join(mounts, " ") + " " + strings.Join(append(variables, command), " "))
+}
+
+// addComposeSecrets declares the build secrets on the app service and at
+// the top level of the compose file
+func addComposeSecrets(compose *ComposeConfig, appService *Service, project *analyzer.ProjectType) {
+	if len(project.Secrets) == 0 {
+		return
+	}
+	compose.Secrets = make(map[string]ComposeSecret)
+	for _, secret := range project.Secrets {
+		appService.Build.Secrets = append(appService.Build.Secrets, secret.ID)
+		if secret.Env != "" {
+			compose.Secrets[secret.ID] = ComposeSecret{Environment: secret.Env}
+		} else {
+			compose.Secrets[secret.ID] = ComposeSecret{File: secret.File}
+		}
+	}
+}
+
+// ignoreSecretFiles adds the project's secret files to the .dockerignore of
+// the build context so `COPY . .` never puts them in a layer
+func ignoreSecretFiles(project *analyzer.ProjectType, outputPath string) error {
+	files := project.SecretFiles()
+	if len(files) == 0 {
+		return nil
+	}
+
+	contextDir := outputPath
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		contextDir = filepath.Join(outputPath, workspace.Root)
+	}
+	ignorePath := filepath.Join(contextDir, ".dockerignore")
+
+	content := ""
+	if data, err := ioutil.ReadFile(ignorePath); err == nil {
+		content = string(data)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	var missing []string
+	for _, file := range files {
+		rel, err := filepath.Rel(contextDir, filepath.Join(outputPath, file))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !existing[rel] {
+			missing = append(missing, rel)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += "# Credentials are passed as build secrets\n" + strings.Join(missing, "\n") + "\n"
+	return os.WriteFile(ignorePath, []byte(content), 0644)
+}
@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dockerizer-cli/internal/analyzer"
+)
+
+// writeGunicornConfig writes the gunicorn.conf.py gunicorn loads from the
+// working directory of the production image. An existing one is kept.
+func writeGunicornConfig(project *analyzer.ProjectType, port int, outputPath string) error {
+	configPath := filepath.Join(outputPath, "gunicorn.conf.py")
+	if _, err := os.Stat(configPath); err == nil {
+		return nil
+	}
+	if len(project.Ports) > 0 {
+		fmt.Sscan(project.Ports[0], &port)
+	}
+
+	workerClass := ""
+	if project.AppServer.ASGI {
+		workerClass = `
+# ASGI applications run in uvicorn's event loop
+worker_class = "uvicorn.workers.UvicornWorker"
+`
+	}
+
+	config := fmt.Sprintf(`# gunicorn settings of the production image
+import os
+
+
+def cpu_limit():
+    """CPUs the container may use, honoring the quota set by docker --cpus"""
+    try:
+        with open("/sys/fs/cgroup/cpu.max") as f:
+            quota, period = f.read().split()
+        if quota != "max":
+            return max(1, int(quota) // int(period))
+    except (OSError, ValueError):
+        pass
+    return len(os.sched_getaffinity(0))
+
+
+bind = "0.0.0.0:" + os.environ.get("PORT", "%d")
+
+# WEB_CONCURRENCY sets the number of workers, two per CPU plus one by default
+workers = int(os.environ.get("WEB_CONCURRENCY", cpu_limit() * 2 + 1))
+%s
+# Log requests and errors to the container output
+accesslog = "-"
+errorlog = "-"
+`, port, workerClass)
+
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to create gunicorn configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dockerizer-cli/internal/analyzer"
+)
+
+// multiPageFrameworks generate one HTML file per route, so unknown paths must
+// return the site's 404 page instead of falling back to index.html
+var multiPageFrameworks = map[string]bool{
+	"hugo":  true,
+	"astro": true,
+}
+
+// writeStaticSiteConfig writes the nginx configuration used to serve the
+// build output of static sites
+func writeStaticSiteConfig(project *analyzer.ProjectType, outputPath string) error {
+	port := "8080"
+	if len(project.Ports) > 0 {
+		port = project.Ports[0]
+	}
+
+	fallback := `    # Single page application: let the client-side router handle unknown paths
+    location / {
+        try_files $uri $uri/ /index.html;
+    }`
+	if multiPageFrameworks[project.Framework] {
+		fallback = `    location / {
+        try_files $uri $uri/ $uri.html =404;
+    }
+
+    error_page 404 /404.html;`
+	}
+
+	nginxConfig := fmt.Sprintf(`server {
+    listen %s;
+    server_name localhost;
+    root /usr/share/nginx/html;
+    index index.html;
+
+    gzip on;
+    gzip_vary on;
+    gzip_min_length 1024;
+    gzip_proxied any;
+    gzip_types text/plain text/css text/xml text/javascript application/javascript application/json application/xml image/svg+xml font/woff2;
+
+    # Fingerprinted assets can be cached forever
+    location ~* \.(?:js|css|mjs|woff2?|ttf|otf|eot|svg|png|jpe?g|gif|webp|avif|ico)$ {
+        expires 1y;
+        add_header Cache-Control "public, max-age=31536000, immutable";
+        try_files $uri =404;
+    }
+
+    # HTML must always be revalidated so new deployments are picked up
+    location ~* \.html$ {
+        add_header Cache-Control "no-cache";
+        try_files $uri =404;
+    }
+
+%s
+}
+`, port, fallback)
+
+	nginxConfigDir := filepath.Join(outputPath, "docker", "nginx")
+	if err := os.MkdirAll(nginxConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create nginx config directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(nginxConfigDir, "default.conf"), []byte(nginxConfig), 0644); err != nil {
+		return fmt.Errorf("failed to create nginx configuration: %w", err)
+	}
+	return nil
+}
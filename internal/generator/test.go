@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testStage is the name of the stage that runs the tests
+const testStage = "test"
+
+// addTestStage adds a stage running the project's tests after the dev
+// stage, which already has the dev dependencies installed
+func addTestStage(df *dockerfile.Dockerfile, project *analyzer.ProjectType) {
+	dev := df.Stage(devStage)
+	if project.TestCommand == "" || dev == nil {
+		return
+	}
+
+	stage := &dockerfile.Stage{
+		Comments: []string{"Test stage, run by compose.test.yml"},
+		Image:    dev.Name,
+		Name:     testStage,
+	}
+	if project.TestInstall != "" {
+		stage.Add("RUN", secretRun(project.Secrets, project.TestInstall))
+	}
+	stage.Add("CMD", execForm(project.TestCommand))
+
+	for i, s := range df.Stages {
+		if s == dev {
+			df.Stages = append(df.Stages[:i+1], append([]*dockerfile.Stage{stage}, df.Stages[i+1:]...)...)
+			break
+		}
+	}
+}
+
+// GenerateComposeTest writes compose.test.yml, which runs the test stage
+// against throwaway databases keeping their data in memory. An existing
+// file is kept.
+func GenerateComposeTest(project *analyzer.ProjectType, outputPath string) error {
+	testPath := filepath.Join(outputPath, "compose.test.yml")
+	if _, err := os.Stat(testPath); err == nil {
+		return fmt.Errorf("compose.test.yml already exists, leaving it unchanged")
+	}
+
+	df, err := dockerfile.ParseFile(filepath.Join(outputPath, "Dockerfile"))
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	if df.Stage(testStage) == nil {
+		return fmt.Errorf("the Dockerfile has no %s stage, skipping compose.test.yml", testStage)
+	}
+
+	compose := &ComposeConfig{
+		Version:  "3.8",
+		Services: make(map[string]Service),
+	}
+	app := Service{
+		Build:              &Build{Context: ".", Dockerfile: "Dockerfile", Target: testStage},
+		Environment:        append([]string(nil), project.Environment...),
+		DependsOnCondition: "service_healthy",
+	}
+	if workspace := project.Workspace; workspace != nil && workspace.Package != "" {
+		app.Build.Context = workspace.Root
+		app.Build.Dockerfile = workspace.Package + "/Dockerfile"
+	}
+	addComposeSecrets(compose, &app, project)
+
+	// The services of docker-compose.yml, without published ports
+	services := make(map[string]Service)
+	if project.Database != "" {
+		if dbConfig := getDefaultDBConfig(project); dbConfig != nil {
+			services[dbConfig.Type] = createDatabaseService(dbConfig)
+			app.Environment = appendEnv(app.Environment, "DATABASE_URL", serviceURL(project, dbConfig.Type))
+		}
+	}
+	if needsCache(project) {
+		services["redis"] = createRedisService()
+		app.Environment = appendEnv(app.Environment, "REDIS_URL", brokerURLs["redis"])
+	}
+	if hasAdditionalService(project, "rabbitmq") {
+		services["rabbitmq"] = createRabbitMQService()
+	}
+	for name, service := range services {
+		compose.Services[name] = testService(service)
+		app.DependsOn = append(app.DependsOn, name)
+	}
+	compose.Services["app"] = app
+
+	// Point variables injected by hosted add-ons at the test services
+	addServiceURLs(compose, project)
+
+	lock, err := readLock(outputPath)
+	if err != nil {
+		return err
+	}
+	pinCompose(compose, lock)
+
+	data, err := yaml.Marshal(compose)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(testPath, data, 0644)
+}
+
+// testService turns a service of docker-compose.yml into one for the tests:
+// its data volumes become tmpfs mounts and nothing is published on the host
+func testService(service Service) Service {
+	test := Service{
+		Image:       service.Image,
+		Environment: service.Environment,
+		HealthCheck: service.HealthCheck,
+	}
+	for _, volume := range service.Volumes {
+		if _, target, ok := strings.Cut(volume, ":"); ok {
+			test.Tmpfs = append(test.Tmpfs, target)
+		}
+	}
+	return test
+}
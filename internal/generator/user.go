@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+// appUser is created in runtime images that do not ship an unprivileged user
+const appUser = "app"
+
+// imageUsers are the unprivileged users that official images already ship,
+// keyed by image repository
+var imageUsers = map[string]string{
+	"node":                             "node",
+	"php":                              "www-data",
+	"oven/bun":                         "bun",
+	"denoland/deno":                    "deno",
+	"nginxinc/nginx-unprivileged":      "nginx",
+	"mcr.microsoft.com/dotnet/aspnet":  "app",
+	"mcr.microsoft.com/dotnet/runtime": "app",
+}
+
+// runtimeUser returns the unprivileged user of an image and whether it has
+// to be created first. Runtime flavors name the user their image ships.
+func runtimeUser(image string, flavor *analyzer.RuntimeFlavor) (user string, create bool) {
+	if flavor != nil && flavor.User != "" {
+		return flavor.User, false
+	}
+	repository, tag, _ := strings.Cut(image, ":")
+	user, ok := imageUsers[repository]
+	if !ok {
+		return appUser, true
+	}
+
+	// .NET images only ship the app user since 8.0
+	if strings.HasPrefix(repository, "mcr.microsoft.com/dotnet/") {
+		major, _, _ := strings.Cut(tag, ".")
+		if version, err := strconv.Atoi(major); err == nil && version < 8 {
+			return appUser, true
+		}
+	}
+	return user, false
+}
+
+// addUserCommand creates a system user with the tools of the image's
+// distribution
+func addUserCommand(image, user string) string {
+	if strings.Contains(image, "alpine") {
+		return "addgroup -S " + user + " && adduser -S -G " + user + " -H " + user
+	}
+	return "groupadd --system " + user + " && useradd --system --gid " + user + " --no-create-home " + user
+}
+
+// applyRuntimeUser makes the final stage run as an unprivileged user. Files
+// are copied with that user as owner, and the working directory and the
+// framework's writable paths (file_permissions in the catalog) are handed to
+// it. Templates that already switch users are left alone.
+func applyRuntimeUser(df *dockerfile.Dockerfile, project *analyzer.ProjectType, framework analyzer.FrameworkConfig, flavor *analyzer.RuntimeFlavor) {
+	stage := df.FinalStage()
+	if stage == nil || len(stage.Find("USER")) > 0 {
+		return
+	}
+	user, create := runtimeUser(stage.Image, flavor)
+	owner := user + ":" + user
+
+	// Running as root, only servers that drop privileges themselves (php-fpm
+	// workers run as www-data) still need their writable paths
+	if project.RunAsRoot {
+		if !create && len(framework.FilePermissions) > 0 {
+			stage.Insert(runIndex(stage), writablePathsRun(stage, framework.FilePermissions, owner, false))
+		}
+		return
+	}
+
+	for _, instruction := range stage.Instructions {
+		if (instruction.Keyword == "COPY" || instruction.Keyword == "ADD") && instruction.Flag("chown") == "" {
+			instruction.Flags = append(instruction.Flags, "--chown="+owner)
+		}
+	}
+
+	if create {
+		index := stage.Index("COPY")
+		if index == -1 {
+			index = runIndex(stage)
+		}
+		stage.Insert(index, &dockerfile.Instruction{
+			Comments: []string{"Unprivileged user to run the application"},
+			Keyword:  "RUN",
+			Args:     addUserCommand(stage.Image, user),
+		})
+	}
+
+	// Images without a shell keep the working directory owned by root
+	shell := flavor == nil || flavor.Shell
+	if writable := writablePathsRun(stage, framework.FilePermissions, owner, shell); writable != nil {
+		stage.Insert(runIndex(stage), writable)
+	}
+	stage.Insert(runIndex(stage), &dockerfile.Instruction{
+		Comments: []string{"Run as an unprivileged user"},
+		Keyword:  "USER",
+		Args:     user,
+	})
+}
+
+// runIndex is where instructions that prepare the runtime go: before
+// EXPOSE, CMD and ENTRYPOINT at the end of the stage
+func runIndex(stage *dockerfile.Stage) int {
+	index := len(stage.Instructions)
+	for index > 0 {
+		switch stage.Instructions[index-1].Keyword {
+		case "EXPOSE", "CMD", "ENTRYPOINT":
+			index--
+			continue
+		}
+		break
+	}
+	return index
+}
+
+// writablePathsRun creates the writable paths and hands them to owner. With
+// workdir set, the working directory itself is included so the application
+// can create files next to its code.
+func writablePathsRun(stage *dockerfile.Stage, paths []string, owner string, workdir bool) *dockerfile.Instruction {
+	var dirs []string
+	if workdir {
+		if workdirs := stage.Find("WORKDIR"); len(workdirs) > 0 {
+			if dir := workdirs[len(workdirs)-1].Args; path.Clean(dir) != "/" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	var command []string
+	if len(paths) > 0 {
+		command = append(command, "mkdir -p "+strings.Join(paths, " "))
+		command = append(command, "chown -R "+owner+" "+strings.Join(paths, " "))
+	}
+	if len(dirs) > 0 {
+		command = append(command, "chown "+owner+" "+strings.Join(dirs, " "))
+	}
+	if len(command) == 0 {
+		return nil
+	}
+	return &dockerfile.Instruction{
+		Comments: []string{"Paths the application writes to at runtime"},
+		Keyword:  "RUN",
+		Args:     strings.Join(command, " \\\n    && "),
+	}
+}
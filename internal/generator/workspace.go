@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"dockerizer-cli/internal/analyzer"
+)
+
+// workspaceExec is how each package manager runs a binary of the workspace
+var workspaceExec = map[string]string{
+	"npm":        "npx",
+	"yarn":       "yarn",
+	"yarn-berry": "yarn",
+	"pnpm":       "pnpm exec",
+}
+
+// workspaceInstall returns the command installing the dependencies of the
+// pruned workspace. The lockfile still lists the packages left out of the
+// build context, so frozen installs (npm ci, --frozen-lockfile) would refuse
+// to run; instead the install is limited to the packages that are present.
+// The workspace root is installed too, it holds tools such as turbo.
+func workspaceInstall(workspace *analyzer.Workspace) string {
+	switch workspace.Manager {
+	case "pnpm":
+		return fmt.Sprintf(`pnpm install --filter "%s..." --filter .`, workspace.Name)
+	case "yarn-berry":
+		return "yarn workspaces focus " + workspace.Name
+	case "yarn":
+		return "yarn install --non-interactive"
+	}
+
+	command := "npm install --no-audit --no-fund --include-workspace-root"
+	for _, member := range workspace.Members {
+		command += " --workspace " + member
+	}
+	return command
+}
+
+// workspaceBuild returns the commands building the package and the workspace
+// packages it depends on, in dependency order
+func workspaceBuild(workspace *analyzer.Workspace) []string {
+	exec := workspaceExec[workspace.Manager]
+	switch workspace.Tool {
+	case "turbo":
+		return []string{fmt.Sprintf("%s turbo run build --filter=%s...", exec, workspace.Name)}
+	case "nx":
+		return []string{fmt.Sprintf("%s nx run %s:build", exec, workspace.Name)}
+	}
+	if workspace.Manager == "pnpm" {
+		return []string{fmt.Sprintf(`pnpm --filter "%s..." run build`, workspace.Name)}
+	}
+
+	var commands []string
+	for _, member := range workspace.Members {
+		pkg, ok := workspace.Member(member)
+		if !ok || pkg.Scripts["build"] == "" {
+			continue
+		}
+		if strings.HasPrefix(workspace.Manager, "yarn") {
+			commands = append(commands, fmt.Sprintf("yarn workspace %s run build", pkg.Name))
+		} else {
+			commands = append(commands, "npm run build --workspace "+member)
+		}
+	}
+	return commands
+}
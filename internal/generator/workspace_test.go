@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dockerizer-cli/internal/analyzer"
+	"dockerizer-cli/internal/dockerfile"
+)
+
+func TestWorkspaceInstallsRoot(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"package.json":          `{"name": "mono", "private": true, "devDependencies": {"turbo": "^2.0.0"}}`,
+		"pnpm-workspace.yaml":   "packages:\n  - \"apps/*\"\n",
+		"pnpm-lock.yaml":        "lockfileVersion: '9.0'\n",
+		"turbo.json":            `{"tasks": {"build": {}}}`,
+		"apps/api/package.json": `{"name": "api", "scripts": {"build": "tsc", "start": "node dist/index.js", "dev": "tsc -w"}, "dependencies": {"express": "^4.18.0"}}`,
+	}
+	writeFiles(t, root, files)
+
+	dir := filepath.Join(root, "apps/api")
+	project, err := analyzer.AnalyzeProject(dir)
+	if err != nil {
+		t.Fatalf("AnalyzeProject: %v", err)
+	}
+	if project.Workspace == nil || project.Workspace.Manager != "pnpm" || project.Workspace.Tool != "turbo" {
+		t.Fatalf("got workspace %+v, want pnpm with turbo", project.Workspace)
+	}
+	if err := GenerateDockerfile(project, dir); err != nil {
+		t.Fatalf("GenerateDockerfile: %v", err)
+	}
+	df, err := dockerfile.ParseFile(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	// turbo is a devDependency of the root, the build runs it
+	for _, name := range []string{"builder", devStage} {
+		var installs []string
+		for _, run := range df.Stage(name).Find("RUN") {
+			if strings.Contains(run.Args, "pnpm install") {
+				installs = append(installs, run.Args)
+			}
+		}
+		if len(installs) != 1 || !strings.Contains(installs[0], `--filter "api..." --filter .`) {
+			t.Errorf("%s stage installs %q, want api's dependencies and the workspace root", name, installs)
+		}
+	}
+	if runs := df.Stage("builder").Find("RUN"); !strings.Contains(runs[len(runs)-1].Args, "turbo run build") {
+		t.Errorf("builder does not build with turbo:\n%s", df)
+	}
+}
@@ -0,0 +1,217 @@
+// Package registry resolves image tags to content digests with the OCI
+// distribution API (registry HTTP API v2).
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DockerHub is the registry of images without a registry host
+const DockerHub = "docker.io"
+
+// manifestTypes are accepted for a tag, preferring the multi-platform
+// indexes so a digest pins every platform of the image
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// challengeParamRe matches the key="value" pairs of a WWW-Authenticate header
+var challengeParamRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Reference is an image reference split into its parts
+type Reference struct {
+	// Registry is the registry host, DockerHub when the image has none
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference such as node:18-alpine or
+// gcr.io/distroless/static@sha256:... the way docker does: the first path
+// component is the registry when it looks like a host, Docker Hub images
+// without a namespace are official library images, and the tag defaults to
+// latest.
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+	name, digest, _ := strings.Cut(ref, "@")
+	r.Digest = digest
+
+	if slash := strings.LastIndex(name, "/"); strings.LastIndex(name, ":") > slash {
+		colon := strings.LastIndex(name, ":")
+		name, r.Tag = name[:colon], name[colon+1:]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	r.Registry, r.Repository = DockerHub, name
+	if host, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		r.Registry, r.Repository = host, rest
+	}
+	if r.Registry == DockerHub && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	if r.Repository == "" || strings.ToLower(r.Repository) != r.Repository || strings.Contains(r.Repository, "$") {
+		return r, fmt.Errorf("invalid image reference %q", ref)
+	}
+	return r, nil
+}
+
+// Client resolves tags with the registry HTTP API
+type Client struct {
+	// Mirror is queried for Docker Hub images instead of Docker Hub, e.g.
+	// localhost:5000 for a local registry. A host without a scheme is
+	// reached over https, except localhost.
+	Mirror string
+	HTTP   *http.Client
+}
+
+// Digest returns the content digest the reference's tag points to
+func (c *Client) Digest(ref string) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Tag == "" {
+		return r.Digest, nil
+	}
+
+	manifestURL := c.baseURL(r.Registry) + "/v2/" + r.Repository + "/manifests/" + r.Tag
+	token := ""
+	resp, err := c.request(http.MethodHead, manifestURL, token)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	// Registries that require a token for public images say where to get it
+	if resp.StatusCode == http.StatusUnauthorized {
+		if token, err = c.token(resp.Header.Get("WWW-Authenticate"), r.Repository); err != nil {
+			return "", err
+		}
+		if resp, err = c.request(http.MethodHead, manifestURL, token); err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); resp.StatusCode == http.StatusOK && digest != "" {
+		return digest, nil
+	}
+	return c.manifestDigest(manifestURL, token)
+}
+
+// manifestDigest downloads the manifest and hashes it, for registries that
+// do not send its digest with HEAD requests
+func (c *Client) manifestDigest(manifestURL, token string) (string, error) {
+	resp, err := c.request(http.MethodGet, manifestURL, token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned %s", resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// request sends a manifest request accepting every manifest type
+func (c *Client) request(method, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient().Do(req)
+}
+
+// token gets an anonymous pull token from the realm of a Bearer challenge
+func (c *Client) token(challenge, repository string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry requires %s authentication, which is not supported", scheme)
+	}
+	values := make(map[string]string)
+	for _, match := range challengeParamRe.FindAllStringSubmatch(params, -1) {
+		values[match[1]] = match[2]
+	}
+	if values["realm"] == "" {
+		return "", fmt.Errorf("registry sent no token realm")
+	}
+
+	query := url.Values{}
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + repository + ":pull"
+	}
+	query.Set("scope", scope)
+
+	resp, err := c.httpClient().Get(values["realm"] + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// baseURL returns the API endpoint of a registry host
+func (c *Client) baseURL(host string) string {
+	if host == DockerHub {
+		if c.Mirror == "" {
+			return "https://registry-1.docker.io"
+		}
+		host = c.Mirror
+	}
+	if strings.Contains(host, "://") {
+		return strings.TrimSuffix(host, "/")
+	}
+	if name := strings.Split(host, ":")[0]; name == "localhost" || name == "127.0.0.1" {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// httpClient returns the client's HTTP client, or the default one
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
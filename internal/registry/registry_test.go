@@ -0,0 +1,163 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const manifest = `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.index.v1+json", "manifests": []}`
+
+// fakeRegistry serves a single manifest. With token set, manifest requests
+// need it as a Bearer token, handed out by /token for the pull scope of
+// the repository. Without sendDigest, HEAD responses lack the digest header.
+type fakeRegistry struct {
+	repository string
+	tag        string
+	token      string
+	sendDigest bool
+	requests   []string
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.URL.Path == "/token" {
+		if r.URL.Query().Get("scope") != "repository:"+f.repository+":pull" {
+			http.Error(w, "bad scope "+r.URL.Query().Get("scope"), http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"token": %q}`, f.token)
+		return
+	}
+
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="fake"`, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/v2/"+f.repository+"/manifests/"+f.tag {
+		http.NotFound(w, r)
+		return
+	}
+	if f.sendDigest || r.Method == http.MethodGet {
+		w.Header().Set("Docker-Content-Digest", manifestDigest())
+	}
+	if r.Method == http.MethodGet {
+		w.Write([]byte(manifest))
+	}
+}
+
+func manifestDigest() string {
+	sum := sha256.Sum256([]byte(manifest))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref      string
+		registry fakeRegistry
+		requests []string
+	}{
+		{
+			name:     "library image on the mirror",
+			ref:      "node:18-alpine",
+			registry: fakeRegistry{repository: "library/node", tag: "18-alpine", sendDigest: true},
+			requests: []string{"HEAD /v2/library/node/manifests/18-alpine"},
+		},
+		{
+			name:     "token challenge",
+			ref:      "bitnami/redis:7.2",
+			registry: fakeRegistry{repository: "bitnami/redis", tag: "7.2", token: "secret", sendDigest: true},
+			requests: []string{
+				"HEAD /v2/bitnami/redis/manifests/7.2",
+				"GET /token",
+				"HEAD /v2/bitnami/redis/manifests/7.2",
+			},
+		},
+		{
+			name:     "HEAD without the digest header",
+			ref:      "postgres",
+			registry: fakeRegistry{repository: "library/postgres", tag: "latest", token: "secret"},
+			requests: []string{
+				"HEAD /v2/library/postgres/manifests/latest",
+				"GET /token",
+				"HEAD /v2/library/postgres/manifests/latest",
+				"GET /v2/library/postgres/manifests/latest",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(&tt.registry)
+			defer server.Close()
+
+			client := &Client{Mirror: server.URL}
+			digest, err := client.Digest(tt.ref)
+			if err != nil {
+				t.Fatalf("Digest: %v (requests %q)", err, tt.registry.requests)
+			}
+			if digest != manifestDigest() {
+				t.Errorf("got digest %s, want %s", digest, manifestDigest())
+			}
+			if fmt.Sprint(tt.registry.requests) != fmt.Sprint(tt.requests) {
+				t.Errorf("got requests %q, want %q", tt.registry.requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestDigestHashesManifest(t *testing.T) {
+	// Registries may send the digest with neither HEAD nor GET
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(manifest))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{Mirror: server.URL}
+	digest, err := client.Digest("alpine:3.19")
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if digest != manifestDigest() {
+		t.Errorf("got digest %s, want the manifest's hash %s", digest, manifestDigest())
+	}
+}
+
+func TestDigestPinnedReference(t *testing.T) {
+	client := &Client{Mirror: "http://127.0.0.1:1"}
+	digest, err := client.Digest("alpine@sha256:abc")
+	if err != nil || digest != "sha256:abc" {
+		t.Errorf("got %q, %v, want the reference's own digest without a request", digest, err)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want Reference
+	}{
+		{"node", Reference{Registry: DockerHub, Repository: "library/node", Tag: "latest"}},
+		{"bitnami/redis:7.2", Reference{Registry: DockerHub, Repository: "bitnami/redis", Tag: "7.2"}},
+		{"localhost:5000/app", Reference{Registry: "localhost:5000", Repository: "app", Tag: "latest"}},
+		{"gcr.io/distroless/static@sha256:abc", Reference{Registry: "gcr.io", Repository: "distroless/static", Digest: "sha256:abc"}},
+		{"mcr.microsoft.com/dotnet/aspnet:8.0@sha256:abc", Reference{Registry: "mcr.microsoft.com", Repository: "dotnet/aspnet", Tag: "8.0", Digest: "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, %v, want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+
+	for _, ref := range []string{"Node", "${IMAGE}:latest"} {
+		if _, err := ParseReference(ref); err == nil {
+			t.Errorf("ParseReference(%q) accepted an invalid reference", ref)
+		}
+	}
+}